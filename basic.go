@@ -22,11 +22,9 @@ SOFTWARE.
 package main
 
 import (
-	"fmt"
 	"math"
 	"strings"
 
-	"github.com/alecthomas/participle/v2"
 	"github.com/alecthomas/participle/v2/lexer"
 	"github.com/alecthomas/repr"
 )
@@ -41,11 +39,37 @@ func (o *Operator) Capture(s []string) error {
 type Value struct {
 	Pos lexer.Position
 
-	Number   *float64 `  @Number`
-	Variable *string  `| @Ident`
-	String   *string  `| @String`
-	//Call          *Call       `| @@`
-	Subexpression *Expression `| "(" @@ ")"`
+	Number        *float64     `  @Number`
+	Word          *string      `| @Word`
+	Text          *string      `| @Text`
+	Variable      *string      `| @Var`
+	List          *ListLiteral `| @@`
+	Subexpression *Paren       `| @@`
+	Negated       *Value       `| Neg @@`
+	Call          *Call        `| @@`
+}
+
+// ListLiteral is a list written out in the program, [like this].
+type ListLiteral struct {
+	Pos    lexer.Position
+	EndPos lexer.Position
+
+	Items []*Expression `"[" ( @@ | EOL )* "]"`
+}
+
+// Paren groups an expression, or lets a procedure take more or fewer inputs
+// than usual, as in (SUM 1 2 3).
+type Paren struct {
+	Pos lexer.Position
+
+	Items []*Expression `"(" EOL* @@ ( @@ | EOL )* ")"`
+}
+
+// Call is a procedure name. Its inputs are the expressions that follow it.
+type Call struct {
+	Pos lexer.Position
+
+	Name string `@Ident`
 }
 
 type Factor struct {
@@ -72,7 +96,7 @@ type Term struct {
 type OpTerm struct {
 	Pos lexer.Position
 
-	Operator Operator `@("+" | "-")`
+	Operator Operator `@("+" | "-":Punct)`
 	Term     *Term    `@@`
 }
 
@@ -86,7 +110,7 @@ type Cmp struct {
 type OpCmp struct {
 	Pos lexer.Position
 
-	Operator Operator `@("=" | "<" "=" | ">" "=" | "<" | ">" | "!" "=")`
+	Operator Operator `@("=" | "<=" | ">=" | "<>" | "!=" | "<" | ">")`
 	Cmp      *Cmp     `@@`
 }
 
@@ -101,20 +125,72 @@ func (v *Value) Evaluate(ctx *Context) (interface{}, error) {
 	switch {
 	case v.Number != nil:
 		return *v.Number, nil
-	case v.String != nil:
-		return *v.String, nil
+	case v.Word != nil:
+		return *v.Word, nil
+	case v.Text != nil:
+		return nil, errorf(v.Pos, "I don't know how to %s", *v.Text)
 	case v.Variable != nil:
-		value, ok := ctx.Vars[*v.Variable]
+		value, ok := ctx.lookup(*v.Variable)
 		if !ok {
-			return nil, fmt.Errorf("unknown variable %q", *v.Variable)
+			return nil, errorf(v.Pos, "%s has no value", *v.Variable)
 		}
 		return value, nil
+	case v.List != nil:
+		return v.List.Evaluate(ctx)
 	case v.Subexpression != nil:
 		return v.Subexpression.Evaluate(ctx)
-		// case v.Call != nil:
-		// 	return v.Call.Evaluate(ctx)
+	case v.Negated != nil:
+		value, err := v.Negated.Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		n, ok := toNumber(value)
+		if !ok {
+			return nil, errorf(v.Pos, "- doesn't like %s as input", FormatValue(value))
+		}
+		return -n, nil
+	case v.Call != nil:
+		return v.Call.Evaluate(ctx)
 	}
-	panic("unsupported value type" + repr.String(v))
+	return nil, errorf(v.Pos, "I don't know what to do with %s", repr.String(v))
+}
+
+// Evaluate turns the literal into a list of words without running anything.
+func (l *ListLiteral) Evaluate(ctx *Context) (interface{}, error) {
+	list := List{Source: l}
+	for _, item := range l.Items {
+		list.Items = append(list.Items, item.Data()...)
+	}
+	return list, nil
+}
+
+func (p *Paren) Evaluate(ctx *Context) (interface{}, error) {
+	s := &stream{items: p.Items}
+	// A procedure in parentheses takes everything up to the ), which can be
+	// nothing, so (PRINT) prints an empty line and (LIST) is [] as in UCBLogo.
+	if call := p.Items[0].call(); call != nil {
+		s.next = 1
+		args, argPos, err := ctx.gather(call.Pos, call.Name, s, -1)
+		if err != nil {
+			return nil, err
+		}
+		return ctx.invoke(call.Pos, call.Name, args, argPos)
+	}
+	value, err := ctx.evalNext(s)
+	if err != nil {
+		return nil, err
+	}
+	if s.more() {
+		return nil, errorf(s.peek().Pos, "too much inside ()")
+	}
+	if value == nil {
+		return nil, errorf(p.Pos, "nothing to output inside ()")
+	}
+	return value, nil
+}
+
+func (c *Call) Evaluate(ctx *Context) (interface{}, error) {
+	return ctx.call(c.Pos, c.Name, ctx.stream, -1)
 }
 
 func (f *Factor) Evaluate(ctx *Context) (interface{}, error) {
@@ -125,25 +201,28 @@ func (f *Factor) Evaluate(ctx *Context) (interface{}, error) {
 	if f.Exponent == nil {
 		return base, nil
 	}
-	baseNum, exponentNum, err := evaluateFloats(ctx, base, f.Exponent)
+	baseNum, exponentNum, err := evaluateFloats(ctx, f.Pos, "^", base, f.Exponent)
 	if err != nil {
-		return nil, participle.Errorf(f.Pos, "invalid factor: %s", err)
+		return nil, err
 	}
 	return math.Pow(baseNum, exponentNum), nil
 }
 
 func (o *OpFactor) Evaluate(ctx *Context, lhs interface{}) (interface{}, error) {
-	lhsNumber, rhsNumber, err := evaluateFloats(ctx, lhs, o.Factor)
+	lhsNumber, rhsNumber, err := evaluateFloats(ctx, o.Pos, string(o.Operator), lhs, o.Factor)
 	if err != nil {
-		return nil, participle.Errorf(o.Pos, "invalid arguments for %s: %s", o.Operator, err)
+		return nil, err
 	}
 	switch o.Operator {
 	case "*":
 		return lhsNumber * rhsNumber, nil
 	case "/":
+		if rhsNumber == 0 {
+			return nil, errorf(o.Pos, "/ can't divide by zero")
+		}
 		return lhsNumber / rhsNumber, nil
 	}
-	panic("unreachable")
+	return nil, errorf(o.Pos, "I don't know how to %s", o.Operator)
 }
 
 func (t *Term) Evaluate(ctx *Context) (interface{}, error) {
@@ -162,9 +241,9 @@ func (t *Term) Evaluate(ctx *Context) (interface{}, error) {
 }
 
 func (o *OpTerm) Evaluate(ctx *Context, lhs interface{}) (interface{}, error) {
-	lhsNumber, rhsNumber, err := evaluateFloats(ctx, lhs, o.Term)
+	lhsNumber, rhsNumber, err := evaluateFloats(ctx, o.Pos, string(o.Operator), lhs, o.Term)
 	if err != nil {
-		return nil, participle.Errorf(o.Pos, "invalid arguments for %s: %s", o.Operator, err)
+		return nil, err
 	}
 	switch o.Operator {
 	case "+":
@@ -172,7 +251,7 @@ func (o *OpTerm) Evaluate(ctx *Context, lhs interface{}) (interface{}, error) {
 	case "-":
 		return lhsNumber - rhsNumber, nil
 	}
-	panic("unreachable")
+	return nil, errorf(o.Pos, "I don't know how to %s", o.Operator)
 }
 
 func (c *Cmp) Evaluate(ctx *Context) (interface{}, error) {
@@ -195,36 +274,18 @@ func (o *OpCmp) Evaluate(ctx *Context, lhs interface{}) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	switch lhs := lhs.(type) {
-	case float64:
-		rhs, ok := rhs.(float64)
-		if !ok {
-			return nil, participle.Errorf(o.Pos, "rhs of %s must be a number", o.Operator)
-		}
-		switch o.Operator {
-		case "=":
-			return lhs == rhs, nil
-		case "!=":
-			return lhs != rhs, nil
-		case "<":
-			return lhs < rhs, nil
-		case ">":
-			return lhs > rhs, nil
-		case "<=":
-			return lhs <= rhs, nil
-		case ">=":
-			return lhs >= rhs, nil
-		}
-	case string:
-		rhs, ok := rhs.(string)
+	switch o.Operator {
+	case "=":
+		return valuesEqual(lhs, rhs), nil
+	case "!=", "<>":
+		return !valuesEqual(lhs, rhs), nil
+	}
+	if lhs, ok := toNumber(lhs); ok {
+		rhs, ok := toNumber(rhs)
 		if !ok {
-			return nil, participle.Errorf(o.Pos, "rhs of %s must be a string", o.Operator)
+			return nil, errorf(o.Pos, "%s doesn't like %s as input", o.Operator, FormatValue(rhs))
 		}
 		switch o.Operator {
-		case "=":
-			return lhs == rhs, nil
-		case "!=":
-			return lhs != rhs, nil
 		case "<":
 			return lhs < rhs, nil
 		case ">":
@@ -234,10 +295,26 @@ func (o *OpCmp) Evaluate(ctx *Context, lhs interface{}) (interface{}, error) {
 		case ">=":
 			return lhs >= rhs, nil
 		}
-	default:
-		return nil, participle.Errorf(o.Pos, "lhs of %s must be a number or string", o.Operator)
 	}
-	panic("unreachable")
+	lhsWord, ok := lhs.(string)
+	if !ok {
+		return nil, errorf(o.Pos, "%s doesn't like %s as input", o.Operator, FormatValue(lhs))
+	}
+	rhsWord, ok := rhs.(string)
+	if !ok {
+		return nil, errorf(o.Pos, "%s doesn't like %s as input", o.Operator, FormatValue(rhs))
+	}
+	switch o.Operator {
+	case "<":
+		return lhsWord < rhsWord, nil
+	case ">":
+		return lhsWord > rhsWord, nil
+	case "<=":
+		return lhsWord <= rhsWord, nil
+	case ">=":
+		return lhsWord >= rhsWord, nil
+	}
+	return nil, errorf(o.Pos, "I don't know how to %s", o.Operator)
 }
 
 func (e *Expression) Evaluate(ctx *Context) (interface{}, error) {
@@ -255,39 +332,145 @@ func (e *Expression) Evaluate(ctx *Context) (interface{}, error) {
 	return lhs, nil
 }
 
-// func (c *Call) Evaluate(ctx *Context) (interface{}, error) {
-// 	function, ok := ctx.Functions[c.Name]
-// 	if !ok {
-// 		return nil, participle.Errorf(c.Pos, "unknown function %q", c.Name)
-// 	}
-// 	args := []interface{}{}
-// 	for _, arg := range c.Args {
-// 		value, err := arg.Evaluate(ctx)
-// 		if err != nil {
-// 			return nil, err
-// 		}
-// 		args = append(args, value)
-// 	}
-
-// 	value, err := function(args...)
-// 	if err != nil {
-// 		return nil, participle.Errorf(c.Pos, "call to %s() failed", c.Name)
-// 	}
-// 	return value, nil
-// }
-
-func evaluateFloats(ctx *Context, lhs interface{}, rhsExpr Evaluatable) (float64, float64, error) {
+// evaluateFloats evaluates the right hand side of the infix operator op and
+// checks that both sides are numbers.
+func evaluateFloats(ctx *Context, pos lexer.Position, op string, lhs interface{}, rhsExpr Evaluatable) (float64, float64, error) {
 	rhs, err := rhsExpr.Evaluate(ctx)
 	if err != nil {
 		return 0, 0, err
 	}
-	lhsNumber, ok := lhs.(float64)
+	if rhs == nil {
+		return 0, 0, errorf(pos, "nothing to input to %s", op)
+	}
+	lhsNumber, ok := toNumber(lhs)
 	if !ok {
-		return 0, 0, fmt.Errorf("lhs must be a number")
+		return 0, 0, errorf(pos, "%s doesn't like %s as input", op, FormatValue(lhs))
 	}
-	rhsNumber, ok := rhs.(float64)
+	rhsNumber, ok := toNumber(rhs)
 	if !ok {
-		return 0, 0, fmt.Errorf("rhs must be a number")
+		return 0, 0, errorf(pos, "%s doesn't like %s as input", op, FormatValue(rhs))
 	}
 	return lhsNumber, rhsNumber, nil
 }
+
+// value returns the value e is made of, if it has no operators.
+func (e *Expression) value() *Value {
+	if len(e.Right) != 0 || len(e.Left.Right) != 0 || len(e.Left.Left.Right) != 0 || e.Left.Left.Left.Exponent != nil {
+		return nil
+	}
+	return e.Left.Left.Left.Base
+}
+
+// call returns the procedure call if e is nothing but a procedure name.
+func (e *Expression) call() *Call {
+	if v := e.value(); v != nil {
+		return v.Call
+	}
+	return nil
+}
+
+// values returns the values of e in the order they are evaluated.
+func (e *Expression) values() []*Value {
+	var values []*Value
+	term := func(t *Term) {
+		for _, f := range append([]*Factor{t.Left}, factors(t.Right)...) {
+			values = append(values, f.Base)
+			if f.Exponent != nil {
+				values = append(values, f.Exponent)
+			}
+		}
+	}
+	cmp := func(c *Cmp) {
+		term(c.Left)
+		for _, right := range c.Right {
+			term(right.Term)
+		}
+	}
+	cmp(e.Left)
+	for _, right := range e.Right {
+		cmp(right.Cmp)
+	}
+	return values
+}
+
+func factors(ops []*OpFactor) []*Factor {
+	factors := make([]*Factor, len(ops))
+	for i, op := range ops {
+		factors[i] = op.Factor
+	}
+	return factors
+}
+
+// Data returns the words e is made of, for when it appears inside a list.
+func (e *Expression) Data() []interface{} {
+	data := e.Left.Data()
+	for _, right := range e.Right {
+		data = append(data, string(right.Operator))
+		data = append(data, right.Cmp.Data()...)
+	}
+	return data
+}
+
+func (c *Cmp) Data() []interface{} {
+	data := c.Left.Data()
+	for _, right := range c.Right {
+		data = append(data, string(right.Operator))
+		data = append(data, right.Term.Data()...)
+	}
+	return data
+}
+
+func (t *Term) Data() []interface{} {
+	data := t.Left.Data()
+	for _, right := range t.Right {
+		data = append(data, string(right.Operator))
+		data = append(data, right.Factor.Data()...)
+	}
+	return data
+}
+
+func (f *Factor) Data() []interface{} {
+	data := f.Base.Data()
+	if f.Exponent != nil {
+		data = append(data, "^")
+		data = append(data, f.Exponent.Data()...)
+	}
+	return data
+}
+
+func (v *Value) Data() []interface{} {
+	switch {
+	case v.Number != nil:
+		return []interface{}{*v.Number}
+	case v.Word != nil:
+		return []interface{}{quoteWord(*v.Word)}
+	case v.Text != nil:
+		// In a list, |a b| is the word a b.
+		text, _ := unbar(*v.Text)
+		return []interface{}{text}
+	case v.Variable != nil:
+		return []interface{}{":" + *v.Variable}
+	case v.List != nil:
+		list, _ := v.List.Evaluate(nil)
+		return []interface{}{list}
+	case v.Subexpression != nil:
+		data := []interface{}{"("}
+		for _, item := range v.Subexpression.Items {
+			data = append(data, item.Data()...)
+		}
+		return append(data, ")")
+	case v.Negated != nil:
+		data := v.Negated.Data()
+		if n, ok := data[0].(float64); ok && len(data) == 1 {
+			return []interface{}{-n}
+		}
+		if w, ok := data[0].(string); ok {
+			data[0] = "-" + w
+			return data
+		}
+		return append([]interface{}{"-"}, data...)
+	case v.Call != nil:
+		return []interface{}{v.Call.Name}
+	}
+	panic("unsupported value type" + repr.String(v))
+}
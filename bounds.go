@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Paper sizes in millimetres, portrait. How many turtle steps that is depends
+// on how far the turtle goes in a step, which is -stepmm.
+var papers = map[string][2]float64{
+	"a3":     {297, 420},
+	"a4":     {210, 297},
+	"a5":     {148, 210},
+	"letter": {215.9, 279.4},
+	"legal":  {215.9, 355.6},
+}
+
+// ParsePaper reads a paper size like a4 or letter, or a width and height like
+// 297x210.
+func ParsePaper(name string) (width, height float64, err error) {
+	if size, ok := papers[strings.ToLower(name)]; ok {
+		return size[0], size[1], nil
+	}
+	if i := strings.Index(name, "x"); i >= 0 {
+		width, errW := strconv.ParseFloat(name[:i], 64)
+		height, errH := strconv.ParseFloat(name[i+1:], 64)
+		if errW == nil && errH == nil && width > 0 && height > 0 {
+			return width, height, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("unknown paper %q, want a3, a4, a5, letter, legal or a size like 297x210", name)
+}
+
+// BoundsMode is what happens when the turtle gets to the edge of the page.
+type BoundsMode int
+
+const (
+	// Window lets the turtle go anywhere.
+	Window BoundsMode = iota
+	// Fence stops the turtle before it leaves the page.
+	Fence
+	// Wrap takes the turtle round to the other side of the page.
+	Wrap
+)
+
+// ErrOffPage is returned in Fence mode for a move that would leave the page.
+var ErrOffPage = errors.New("the turtle would go off the page")
+
+// BoundedTurtle keeps Turtle inside a Width by Height area, in turtle steps in
+// the Logo frame with home in the middle, checking each move before passing
+// it on so that nothing moves if it would go off the page.
+type BoundedTurtle struct {
+	Turtle
+	Width, Height float64
+	Mode          BoundsMode
+}
+
+func NewBoundedTurtle(t Turtle, width, height float64) *BoundedTurtle {
+	return &BoundedTurtle{Turtle: t, Width: width, Height: height}
+}
+
+// inside reports whether (x, y) is on the page, allowing for rounding.
+func (t *BoundedTurtle) inside(x, y float64) bool {
+	const slack = 1e-9
+	return math.Abs(x) <= t.Width/2+slack && math.Abs(y) <= t.Height/2+slack
+}
+
+// SetMode switches to mode, which fails unless the turtle is on the page for
+// Fence and Wrap.
+func (t *BoundedTurtle) SetMode(mode BoundsMode) error {
+	if mode != Window && !t.inside(LogoPos(t.State())) {
+		return errors.New("the turtle is off the page")
+	}
+	t.Mode = mode
+	return nil
+}
+
+// Reach refuses to go off the page in Fence mode, so that SETXY doesn't turn
+// the turtle towards somewhere it won't go.
+func (t *BoundedTurtle) Reach(x, y float64) error {
+	if t.Mode == Fence && !t.inside(x, y) {
+		return ErrOffPage
+	}
+	return nil
+}
+
+func (t *BoundedTurtle) Move(steps float64) (x, y float64, err error) {
+	switch t.Mode {
+	case Fence:
+		x, y := LogoPos(t.State())
+		dx, dy := t.direction()
+		if err := t.Reach(x+dx*steps, y+dy*steps); err != nil {
+			s := t.State()
+			return s.X, s.Y, err
+		}
+	case Wrap:
+		if err := t.wrap(steps); err != nil {
+			s := t.State()
+			return s.X, s.Y, err
+		}
+		s := t.State()
+		return s.X, s.Y, nil
+	}
+	return t.Turtle.Move(steps)
+}
+
+// direction is the way the turtle faces in the Logo frame.
+func (t *BoundedTurtle) direction() (dx, dy float64) {
+	return math.Sincos(LogoHeading(t.State()) * math.Pi / 180)
+}
+
+// wrap moves steps, and each time the turtle gets to an edge takes it across
+// to the other side with the pen up and carries on from there.
+func (t *BoundedTurtle) wrap(steps float64) error {
+	dx, dy := t.direction()
+	if steps < 0 {
+		dx, dy = -dx, -dy
+	}
+	left := math.Abs(steps)
+	for left > 1e-9 {
+		x, y := LogoPos(t.State())
+		// How far it is to the edge each way.
+		toX, toY := math.Inf(1), math.Inf(1)
+		if dx != 0 {
+			toX = math.Max((math.Copysign(t.Width/2, dx)-x)/dx, 0)
+		}
+		if dy != 0 {
+			toY = math.Max((math.Copysign(t.Height/2, dy)-y)/dy, 0)
+		}
+		along := math.Min(math.Min(toX, toY), left)
+		if along > 1e-9 {
+			if _, _, err := t.Turtle.Move(math.Copysign(along, steps)); err != nil {
+				return err
+			}
+		}
+		left -= along
+		if left <= 1e-9 {
+			return nil
+		}
+
+		x, y = LogoPos(t.State())
+		if toX <= toY {
+			x = -x
+		}
+		if toY <= toX {
+			y = -y
+		}
+		start := t.State()
+		if _, err := t.Turtle.PenUp(true); err != nil {
+			return err
+		}
+		if err := lineTo(t.Turtle, x, y); err != nil {
+			return err
+		}
+		if err := SetHeading(t.Turtle, LogoHeading(start)); err != nil {
+			return err
+		}
+		if _, err := t.Turtle.PenUp(start.IsPenUp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bounded finds the turtle FENCE, WRAP and WINDOW work on.
+func (in *Inputs) bounded(ctx *Context) (*BoundedTurtle, error) {
+	t, ok := ctx.Turtle.(*BoundedTurtle)
+	if !ok {
+		return nil, in.errorf("%s needs a turtle that knows the size of the page", in.Name)
+	}
+	return t, nil
+}
+
+func init() {
+	modes := map[string]BoundsMode{"WINDOW": Window, "FENCE": Fence, "WRAP": Wrap}
+	for name, mode := range modes {
+		mode := mode
+		definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+			t, err := in.bounded(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if err := t.SetMode(mode); err != nil {
+				return nil, in.errorf("%s failed: %v", in.Name, err)
+			}
+			return nil, nil
+		}), name)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+func TestParsePaper(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height float64
+		ok            bool
+	}{
+		{"a4", 210, 297, true},
+		{"Letter", 215.9, 279.4, true},
+		{"297x210", 297, 210, true},
+		{"a9", 0, 0, false},
+		{"0x10", 0, 0, false},
+	}
+	for _, test := range tests {
+		width, height, err := ParsePaper(test.name)
+		if (err == nil) != test.ok || width != test.width || height != test.height {
+			t.Errorf("ParsePaper(%q) = %v, %v, %v", test.name, width, height, err)
+		}
+	}
+}
+
+func TestFenceSetXYLeavesTurtle(t *testing.T) {
+	turtle := NewBoundedTurtle(&SVGTurtle{}, 100, 100)
+	ctx := NewContext(turtle, nil, ioutil.Discard, nil)
+	if _, err := resultIn(ctx, "FD 10 RT 30 FENCE"); err != nil {
+		t.Fatal(err)
+	}
+	before := turtle.State()
+	for _, src := range []string{"SETXY 80 0", "SETPOS [0 -60]"} {
+		if _, err := resultIn(ctx, src); err == nil {
+			t.Errorf("Run(%q) = nil, want the turtle fenced in", src)
+		}
+		if turtle.State() != before {
+			t.Errorf("Run(%q) moved the turtle from %+v to %+v", src, before, turtle.State())
+		}
+	}
+}
+
+func TestBounds(t *testing.T) {
+	tests := []struct {
+		src          string
+		wantX, wantY float64
+		wantErr      bool
+	}{
+		{"FD 80", 0, 80, false},
+		{"FENCE FD 40 FD 20", 0, 40, true},
+		{"FENCE RT 90 BK 50", -50, 0, false},
+		{"WRAP FD 80", 0, -20, false},
+		{"WRAP RT 45 FD 100 * SQRT 2", 0, 0, false},
+		{"FD 60 WRAP", 0, 60, true},
+	}
+	for _, test := range tests {
+		program, err := ParseString("", test.src)
+		if err != nil {
+			t.Fatalf("Parse(%q) = %v", test.src, err)
+		}
+		inner := &SVGTurtle{}
+		turtle := NewBoundedTurtle(inner, 100, 100)
+		err = NewContext(turtle, nil, ioutil.Discard, nil).RunProgram(program)
+		if (err != nil) != test.wantErr {
+			t.Errorf("Run(%q) = %v, want error %v", test.src, err, test.wantErr)
+		}
+		x, y := LogoPos(turtle.State())
+		if math.Abs(x-test.wantX) > 1e-9 || math.Abs(y-test.wantY) > 1e-9 {
+			t.Errorf("Run(%q) left the turtle at (%v, %v), want (%v, %v)", test.src, x, y, test.wantX, test.wantY)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// throwSignal unwinds to the CATCH with the same tag.
+type throwSignal struct {
+	Pos   lexer.Position
+	Tag   string
+	Value interface{}
+}
+
+func (s *throwSignal) Error() string {
+	return errorf(s.Pos, "Can't find catch tag for %s", s.Tag).Error()
+}
+
+// CATCH "ERROR catches errors, after which ERROR outputs
+// [[message words] line column], or [] if nothing has gone wrong since it was
+// last asked.
+
+// catchable reports whether CATCH "ERROR should catch err. Other signals, and
+// being canceled, have to get where they are going.
+func catchable(err error) bool {
+	switch err.(type) {
+	case *stopSignal, *outputSignal, *throwSignal:
+		return false
+	}
+	return err != ErrCanceled
+}
+
+// messageList splits an error message into a list of words.
+func messageList(msg string) List {
+	var words []interface{}
+	for _, word := range strings.Fields(msg) {
+		words = append(words, word)
+	}
+	return NewList(words...)
+}
+
+func init() {
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		tag, err := in.Word(0)
+		if err != nil {
+			return nil, err
+		}
+		value, err := ctx.RunValue(in, 1, true)
+		if signal, ok := err.(*throwSignal); ok && strings.EqualFold(signal.Tag, tag) {
+			return signal.Value, nil
+		}
+		if err != nil && strings.EqualFold(tag, "error") && catchable(err) {
+			ctx.lastError = err
+			return nil, nil
+		}
+		return value, err
+	}), "CATCH")
+	definePrimitive(&Primitive{Inputs: 1, MinInputs: 1, MaxInputs: 2, Fn: func(ctx *Context, in *Inputs) (interface{}, error) {
+		tag, err := in.Word(0)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if len(in.Values) == 2 {
+			value = in.Values[1]
+		}
+		if strings.EqualFold(tag, "error") {
+			if value == nil {
+				return nil, in.errorf("Throw \"Error")
+			}
+			return nil, in.errorf("%s", PrintValue(value))
+		}
+		return nil, &throwSignal{Pos: in.Pos, Tag: tag, Value: value}
+	}}, "THROW")
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		err := ctx.lastError
+		ctx.lastError = nil
+		if err == nil {
+			return NewList(), nil
+		}
+		if rerr, ok := err.(*Error); ok {
+			return NewList(messageList(rerr.Msg), float64(rerr.Pos.Line), float64(rerr.Pos.Column)), nil
+		}
+		return NewList(messageList(err.Error())), nil
+	}), "ERROR")
+}
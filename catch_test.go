@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCatch(t *testing.T) {
+	runResults(t, []resultTest{
+		{"MAKE \"x 1 CATCH \"done [THROW \"done MAKE \"x 2] :x", "1"},
+		{"CATCH \"done [(THROW \"done 42)]", "42"},
+		{"CATCH \"Done [(THROW \"dONE 42)]", "42"},
+		{"CATCH \"done [\"finished]", "finished"},
+		// The innermost CATCH with the tag gets it, and the others let it by.
+		{"CATCH \"t [(LIST CATCH \"t [(THROW \"t 1)] 2)]", "[1 2]"},
+		{"CATCH \"b [CATCH \"a [(THROW \"b 5)] 6]", "5"},
+		{"TO F\n(THROW \"up 3)\nEND\nCATCH \"up [F]", "3"},
+	})
+	runErrors(t, []resultTest{
+		{"CATCH \"a [THROW \"b]", "1:11: Can't find catch tag for b"},
+		{"TO F\nTHROW \"oops\nEND\nF", "2:1: Can't find catch tag for oops"},
+	})
+}
+
+func TestCatchError(t *testing.T) {
+	runResults(t, []resultTest{
+		{"CATCH \"ERROR [FD \"x] ERROR", "[[FD doesn't like x as input] 1 18]"},
+		{"CATCH \"ERROR [FD \"x] MAKE \"e ERROR ERROR", "[]"},
+		{"ERROR", "[]"},
+		{"CATCH \"ERROR [(THROW \"ERROR [out of cheese])] FIRST ERROR", "[out of cheese]"},
+		{"CATCH \"ERROR [PRINT 1 THROW \"ERROR PRINT 2] FIRST ERROR", "1\n[Throw \"Error]"},
+	})
+	runReading(t, "", []resultTest{
+		{"CATCH \"ERROR [PRINT RW] \"after", "after"},
+	})
+	// Only errors are caught, not other tags.
+	runErrors(t, []resultTest{
+		{"CATCH \"ERROR [THROW \"x]", "1:15: Can't find catch tag for x"},
+	})
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Check looks for mistakes in a program without running it: procedures that
+// don't exist, calls with the wrong number of inputs, variables nothing gives
+// a value, instructions after STOP that can never run and REPEAT counts that
+// aren't numbers. Files the program LOADs are looked for as LOAD would, in
+// searchPath too, so that the procedures they define are known.
+func Check(p *Program, searchPath []string) []*Error {
+	return newChecker(p, searchPath).problems
+}
+
+// codeLists finds the list literals in p that are run as instructions.
+func codeLists(p *Program) map[*ListLiteral]bool {
+	return newChecker(p, nil).code
+}
+
+func newChecker(p *Program, searchPath []string) *checker {
+	c := &checker{
+		procs:      map[string]int{},
+		vars:       map[string]bool{},
+		code:       map[*ListLiteral]bool{},
+		searchPath: searchPath,
+		loaded:     map[string]bool{},
+	}
+	for name, proc := range library {
+		c.procs[name] = len(proc.Params)
+	}
+	c.define(p)
+	for _, line := range p.Lines {
+		if line.To != nil {
+			c.lines(line.To.Body)
+		} else {
+			c.stream(line.Items, false)
+		}
+	}
+	sort.SliceStable(c.problems, func(i, j int) bool {
+		a, b := c.problems[i].Pos, c.problems[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return c
+}
+
+// runs are the inputs of primitives that are lists of instructions, with -1
+// for the last input. Lists anywhere else are taken to be data.
+var runs = map[string][]int{
+	"REPEAT": {1}, "FOREVER": {0}, "FOR": {1}, "IF": {1, 2}, "IFELSE": {1, 2},
+	"WHILE": {0, 1}, "UNTIL": {0, 1}, "DO.WHILE": {0, 1}, "DO.UNTIL": {0, 1},
+	"CATCH": {1}, "RUN": {0}, "APPLY": {0}, "MAP": {0}, "FILTER": {0},
+	"FOREACH": {-1}, "REDUCE": {0},
+}
+
+// reports are the primitives whose instruction lists may output a value.
+var reports = map[string]bool{
+	"IF": true, "IFELSE": true, "WHILE": true, "UNTIL": true, "DO.WHILE": true,
+	"DO.UNTIL": true, "CATCH": true, "RUN": true, "APPLY": true, "MAP": true,
+	"FILTER": true, "REDUCE": true,
+}
+
+// stops are the primitives nothing after which runs.
+var stops = map[string]bool{"STOP": true, "OUTPUT": true, "OP": true, "THROW": true}
+
+type checker struct {
+	// Number of inputs of each procedure defined with TO.
+	procs map[string]int
+	// Variables something in the program gives a value.
+	vars map[string]bool
+	// Lists that are run as instructions.
+	code     map[*ListLiteral]bool
+	problems []*Error
+
+	searchPath []string
+	// Files LOADed so far.
+	loaded map[string]bool
+	// unknownLoad is set if the program LOADs a file that couldn't be
+	// read, so any procedure might be defined there.
+	unknownLoad bool
+}
+
+// define notes the procedures p defines and the variables it gives values.
+func (c *checker) define(p *Program) {
+	for _, line := range p.Lines {
+		if line.To != nil {
+			c.procs[strings.ToUpper(line.To.Name)] = len(line.To.Params)
+			for _, param := range line.To.Params {
+				c.vars[strings.ToLower(param)] = true
+			}
+			for _, body := range line.To.Body {
+				c.collect(body.Items)
+			}
+		} else {
+			c.collect(line.Items)
+		}
+	}
+}
+
+// load reads the file LOAD "name would, from a program in the file from, and
+// notes what it defines.
+func (c *checker) load(from, name string) {
+	path, ok := findFile(baseDir(from), c.searchPath, name)
+	if !ok {
+		c.unknownLoad = true
+		return
+	}
+	if c.loaded[path] {
+		return
+	}
+	c.loaded[path] = true
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		c.unknownLoad = true
+		return
+	}
+	p, err := ParseString(path, string(src))
+	if err != nil {
+		c.unknownLoad = true
+		return
+	}
+	c.define(p)
+}
+
+func (c *checker) report(pos lexer.Position, format string, args ...interface{}) {
+	c.problems = append(c.problems, errorf(pos, format, args...).(*Error))
+}
+
+// collect finds every variable the program could give a value to, with MAKE,
+// NAME, LOCAL, FOR or a template's inputs, so that using it anywhere is fine.
+func (c *checker) collect(items []*Expression) {
+	for i, item := range items {
+		if call := item.call(); call != nil && i+1 < len(items) {
+			next := items[i+1].value()
+			switch strings.ToUpper(call.Name) {
+			case "LOAD":
+				if next != nil && next.Word != nil {
+					c.load(call.Pos.Filename, *next.Word)
+				} else {
+					c.unknownLoad = true
+				}
+			case "MAKE", "LOCAL":
+				if next != nil && next.Word != nil {
+					c.vars[strings.ToLower(*next.Word)] = true
+				}
+				if next != nil && next.List != nil && strings.EqualFold(call.Name, "LOCAL") {
+					c.names(next.List)
+				}
+			case "NAME":
+				// NAME takes the value first, so the name comes after it.
+				if j := c.skip(items, i+1); j < len(items) {
+					if name := items[j].value(); name != nil && name.Word != nil {
+						c.vars[strings.ToLower(*name.Word)] = true
+					}
+				}
+			case "FOR":
+				if next != nil && next.List != nil && len(next.List.Items) > 0 {
+					if name := next.List.Items[0].call(); name != nil {
+						c.vars[strings.ToLower(name.Name)] = true
+					}
+				}
+			}
+		}
+		for _, v := range item.values() {
+			c.collectValue(v)
+		}
+	}
+}
+
+// skip returns where the input that starts at items[i] ends, counting the
+// inputs of any procedure it calls.
+func (c *checker) skip(items []*Expression, i int) int {
+	call := items[i].call()
+	i++
+	if call == nil {
+		return i
+	}
+	key := strings.ToUpper(call.Name)
+	n, ok := c.procs[key]
+	if !ok {
+		prim, ok := primitives[key]
+		if !ok {
+			return len(items)
+		}
+		n = prim.Inputs
+	}
+	for ; n > 0 && i < len(items); n-- {
+		i = c.skip(items, i)
+	}
+	return i
+}
+
+func (c *checker) collectValue(v *Value) {
+	switch {
+	case v.List != nil:
+		if len(v.List.Items) > 0 {
+			if params := v.List.Items[0].value(); params != nil && params.List != nil {
+				c.names(params.List)
+			}
+		}
+		c.collect(v.List.Items)
+	case v.Subexpression != nil:
+		c.collect(v.Subexpression.Items)
+	case v.Negated != nil:
+		c.collectValue(v.Negated)
+	}
+}
+
+// names marks the words of a list like [x y] as variables.
+func (c *checker) names(list *ListLiteral) {
+	for _, item := range list.Items {
+		if call := item.call(); call != nil {
+			c.vars[strings.ToLower(call.Name)] = true
+		}
+	}
+}
+
+// lines checks the body of a procedure.
+func (c *checker) lines(lines []*Line) {
+	stopped := false
+	for _, line := range lines {
+		if line.To != nil {
+			continue
+		}
+		if stopped {
+			c.report(line.Pos, "this line never runs, it comes after STOP or OUTPUT")
+			return
+		}
+		stopped = c.stream(line.Items, false)
+	}
+}
+
+// stream checks items as a line of instructions, and reports whether it ends
+// with STOP, OUTPUT or THROW. If reporter is set the last instruction may
+// output a value.
+func (c *checker) stream(items []*Expression, reporter bool) bool {
+	s := &stream{items: items}
+	for s.more() {
+		item := s.peek()
+		s.next++
+		if v := item.value(); v != nil && v.Call == nil && v.Subexpression == nil && (!reporter || s.more()) {
+			c.report(item.Pos, "You don't say what to do with %s", formatItems(item.Data()))
+		}
+		c.expression(item, s)
+		if call := item.call(); call != nil && stops[strings.ToUpper(call.Name)] {
+			if s.more() {
+				c.report(s.peek().Pos, "this never runs, it comes after %s", call.Name)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (c *checker) expression(e *Expression, s *stream) {
+	for _, v := range e.values() {
+		c.value(v, s)
+	}
+}
+
+func (c *checker) value(v *Value, s *stream) {
+	switch {
+	case v.Variable != nil:
+		if !c.vars[strings.ToLower(*v.Variable)] && !c.unknownLoad {
+			c.report(v.Pos, ":%s has no value, nothing MAKEs it", *v.Variable)
+		}
+	case v.Subexpression != nil:
+		c.paren(v.Subexpression)
+	case v.Negated != nil:
+		c.value(v.Negated, s)
+	case v.Call != nil:
+		c.call(v.Call, s, false)
+	}
+}
+
+func (c *checker) paren(p *Paren) {
+	s := &stream{items: p.Items, next: 1}
+	if call := p.Items[0].call(); call != nil {
+		c.call(call, s, true)
+		return
+	}
+	c.expression(p.Items[0], s)
+	if s.more() {
+		c.report(s.peek().Pos, "too much inside ()")
+	}
+}
+
+// call checks a call to name, which takes its inputs from s: as many as it
+// usually takes, or all of them if it's in parentheses.
+func (c *checker) call(call *Call, s *stream, paren bool) {
+	key := strings.ToUpper(call.Name)
+	n, min, max := 0, 0, 0
+	if params, ok := c.procs[key]; ok {
+		n, min, max = params, params, params
+	} else if prim, ok := primitives[key]; ok {
+		n, min, max = prim.Inputs, prim.MinInputs, prim.MaxInputs
+	} else if c.unknownLoad {
+		// It could be defined in the file that couldn't be LOADed.
+		s.next = len(s.items)
+		return
+	} else {
+		if suggestion := c.suggest(key); suggestion != "" {
+			c.report(call.Pos, "I don't know how to %s, did you mean %s?", call.Name, suggestion)
+		} else {
+			c.report(call.Pos, "I don't know how to %s", call.Name)
+		}
+		// Without knowing its inputs nothing else on the line makes sense.
+		s.next = len(s.items)
+		return
+	}
+
+	var inputs []*Expression
+	for (paren && s.more()) || (!paren && len(inputs) < n) {
+		if !s.more() {
+			c.report(call.Pos, "not enough inputs to %s, it takes %d", call.Name, n)
+			return
+		}
+		item := s.peek()
+		s.next++
+		c.expression(item, s)
+		inputs = append(inputs, item)
+	}
+	if paren && len(inputs) < min {
+		c.report(call.Pos, "not enough inputs to %s", call.Name)
+	}
+	if paren && max >= 0 && len(inputs) > max {
+		c.report(call.Pos, "too many inputs to %s", call.Name)
+	}
+
+	if key == "REPEAT" && len(inputs) > 0 {
+		if v := inputs[0].value(); v != nil && (v.Word != nil || v.List != nil) {
+			c.report(inputs[0].Pos, "REPEAT doesn't like %s as input, it needs a number", formatItems(inputs[0].Data()))
+		}
+	}
+	for _, i := range runs[key] {
+		if i < 0 {
+			i += len(inputs)
+		}
+		if i < 0 || i >= len(inputs) {
+			continue
+		}
+		if v := inputs[i].value(); v != nil && v.List != nil {
+			c.code[v.List] = true
+			items := v.List.Items
+			if len(items) > 0 {
+				if params := items[0].value(); params != nil && params.List != nil {
+					items = items[1:]
+				}
+			}
+			c.stream(items, reports[key])
+		}
+	}
+}
+
+// suggest finds the procedure name closest to name, if any is close enough
+// to be a typo.
+func (c *checker) suggest(name string) string {
+	var candidates []string
+	for candidate := range primitives {
+		candidates = append(candidates, candidate)
+	}
+	for candidate := range c.procs {
+		candidates = append(candidates, candidate)
+	}
+	sort.Strings(candidates)
+	best, bestDistance := "", len(name)/3+1
+	for _, candidate := range candidates {
+		if d := editDistance(name, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func minInt(n int, rest ...int) int {
+	for _, m := range rest {
+		if m < n {
+			n = m
+		}
+	}
+	return n
+}
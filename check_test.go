@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"REPEAT 4 [FD 10 RT 90]\n", nil},
+		{"REPEAT 1 [FOWARDD 1 BK 1]\n", []string{"1:11: I don't know how to FOWARDD, did you mean FORWARD?"}},
+		{"TO SQ :n\nREPEAT :n [FD 1]\nEND\nSQ\n", []string{"4:1: not enough inputs to SQ, it takes 1"}},
+		{"FD 10 20\n", []string{"1:7: You don't say what to do with 20"}},
+		{"FD :size\n", []string{"1:4: :size has no value, nothing MAKEs it"}},
+		{"TO F :x\nOUTPUT :x\nPRINT 1\nEND\n", []string{"3:1: this line never runs, it comes after STOP or OUTPUT"}},
+		{"REPEAT \"four [FD 1]\n", []string{`1:8: REPEAT doesn't like "four as input, it needs a number`}},
+		{"MAKE \"n 3\nFOR [i 1 :n] [FD :i]\nPRINT MAP [[x] :x * 2] [1 2]\n", nil},
+		{"NAME 3 \"n\nNAME SUM :n 1 \"m\nFD :n + :m\n", nil},
+		{"IF NAME? \"size [FD :size]\n", []string{"1:20: :size has no value, nothing MAKEs it"}},
+	}
+	for _, test := range tests {
+		program, err := ParseString("", test.src)
+		if err != nil {
+			t.Fatalf("Parse(%q) = %v", test.src, err)
+		}
+		var got []string
+		for _, problem := range Check(program, nil) {
+			got = append(got, problem.Error())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Check(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestCheckLoad(t *testing.T) {
+	dir := t.TempDir()
+	lib := "TO SQUARE :size\nREPEAT 4 [FD :size RT 90]\nEND\nMAKE \"side 10\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "shapes.logo"), []byte(lib), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"LOAD \"shapes\nSQUARE :side\n", nil},
+		{"LOAD \"shapes\nSQUARE\n", []string{"2:1: not enough inputs to SQUARE, it takes 1"}},
+		{"SQUARE 10\n", []string{"1:1: I don't know how to SQUARE"}},
+		// Nothing is known about what a missing file would define.
+		{"LOAD \"missing\nSQUARE :side\n", nil},
+		{"LOAD WORD \"sha \"pes\nCIRCLES 10\n", nil},
+	}
+	for _, test := range tests {
+		program, err := ParseString(filepath.Join(dir, "main.logo"), test.src)
+		if err != nil {
+			t.Fatalf("Parse(%q) = %v", test.src, err)
+		}
+		var got []string
+		for _, problem := range Check(program, nil) {
+			got = append(got, fmt.Sprintf("%d:%d: %s", problem.Pos.Line, problem.Pos.Column, problem.Msg))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Check(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+
+	other := t.TempDir()
+	program, err := ParseString(filepath.Join(other, "main.logo"), "LOAD \"shapes\nSQUARE 1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := Check(program, []string{dir}); len(problems) != 0 {
+		t.Errorf("Check with -I %s = %v", dir, problems)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// DisplayList is a turtle that draws nothing but remembers the calls made on
+// it, so that a whole program can be run and checked before any of it is
+// drawn.
+type DisplayList struct {
+	BaseTurtle
+	// Start is where the turtle was before the first call.
+	Start BaseTurtle
+	Calls []TurtleCall
+	// Waits are how long SLEEP waits before call i, or after the last call
+	// if i is len(Calls).
+	Waits map[int]time.Duration
+}
+
+// NewDisplayList starts a display list with the turtle in state start, which
+// should be where the turtle it's played on will be.
+func NewDisplayList(start BaseTurtle) *DisplayList {
+	return &DisplayList{BaseTurtle: start, Start: start}
+}
+
+func (d *DisplayList) Move(steps float64) (x, y float64, err error) {
+	x, y, err = d.BaseTurtle.Move(steps)
+	d.Calls = append(d.Calls, TurtleCall{Call: "move", Steps: &steps, State: d.BaseTurtle})
+	return x, y, err
+}
+
+func (d *DisplayList) Rotate(deg float64) (heading float64, err error) {
+	heading, err = d.BaseTurtle.Rotate(deg)
+	d.Calls = append(d.Calls, TurtleCall{Call: "rotate", Degrees: &deg, State: d.BaseTurtle})
+	return heading, err
+}
+
+func (d *DisplayList) PenUp(state bool) (bool, error) {
+	up, err := d.BaseTurtle.PenUp(state)
+	d.Calls = append(d.Calls, TurtleCall{Call: "penup", Up: &state, State: d.BaseTurtle})
+	return up, err
+}
+
+// Sleep records a wait for SLEEP before the next call.
+func (d *DisplayList) Sleep(wait time.Duration) {
+	if d.Waits == nil {
+		d.Waits = map[int]time.Duration{}
+	}
+	d.Waits[len(d.Calls)] += wait
+}
+
+// Validate finds calls no turtle could carry out, like moving an infinite
+// number of steps after FD TAN 90, calls it doesn't know or that are missing
+// their input, and waits that are negative or not next to any call.
+func (d *DisplayList) Validate() error {
+	for i, c := range d.Calls {
+		move := fmt.Sprintf("move %d of %d", i+1, len(d.Calls))
+		switch c.Call {
+		case "move":
+			if c.Steps == nil {
+				return fmt.Errorf("%s doesn't say how many steps to go", move)
+			}
+			if math.IsInf(*c.Steps, 0) || math.IsNaN(*c.Steps) {
+				return fmt.Errorf("%s can't go %v steps", move, *c.Steps)
+			}
+		case "rotate":
+			if c.Degrees == nil {
+				return fmt.Errorf("%s doesn't say how many degrees to turn", move)
+			}
+			if math.IsInf(*c.Degrees, 0) || math.IsNaN(*c.Degrees) {
+				return fmt.Errorf("%s can't turn %v degrees", move, *c.Degrees)
+			}
+		case "penup":
+			if c.Up == nil {
+				return fmt.Errorf("%s doesn't say whether the pen goes up", move)
+			}
+		default:
+			return fmt.Errorf("%s is %q, want move, rotate or penup", move, c.Call)
+		}
+	}
+	var before []int
+	for i := range d.Waits {
+		before = append(before, i)
+	}
+	sort.Ints(before)
+	for _, i := range before {
+		switch wait := d.Waits[i]; {
+		case i < 0 || i > len(d.Calls):
+			return fmt.Errorf("a wait of %v is before move %d, but there are only %d moves", wait, i+1, len(d.Calls))
+		case wait < 0:
+			return fmt.Errorf("can't wait %v before move %d of %d", wait, i+1, len(d.Calls))
+		}
+	}
+	return nil
+}
+
+// Estimate is how much work a display list is for the turtle.
+type Estimate struct {
+	// Distance travelled with the pen down and up.
+	Drawn, Travel float64
+	// Degrees turned.
+	Turned     float64
+	PenChanges int
+	// How long the pi turtle would take, including waits.
+	Duration time.Duration
+}
+
+func (e Estimate) String() string {
+	return fmt.Sprintf("%.1f drawn, %.1f travelled with the pen up, %.0f degrees turned, %d pen changes, about %v on the pi turtle",
+		e.Drawn, e.Travel, e.Turned, e.PenChanges, e.Duration.Round(time.Second))
+}
+
+// Estimate adds up the work in d.
+func (d *DisplayList) Estimate() Estimate {
+	var e Estimate
+	steps := 0.0
+	for _, c := range d.Calls {
+		switch {
+		case c.Steps != nil && c.State.IsPenUp:
+			e.Travel += math.Abs(*c.Steps)
+			steps += math.Abs(*c.Steps) * StepsPerUnit
+		case c.Steps != nil:
+			e.Drawn += math.Abs(*c.Steps)
+			steps += math.Abs(*c.Steps) * StepsPerUnit
+		case c.Degrees != nil:
+			e.Turned += math.Abs(*c.Degrees)
+			steps += math.Abs(*c.Degrees) * StepsPerDegree
+		case c.Up != nil:
+			e.PenChanges++
+			steps++
+		}
+	}
+	e.Duration = time.Duration(steps) * PiStepDelay
+	for _, wait := range d.Waits {
+		if wait > 0 {
+			e.Duration += wait
+		}
+	}
+	return e
+}
+
+// Play makes the calls in d on t, and waits where the program SLEPT, stopping
+// early once canceled is closed.
+func (d *DisplayList) Play(t TurtleController, canceled <-chan struct{}) error {
+	for i, c := range d.Calls {
+		if err := wait(d.Waits[i], canceled); err != nil {
+			return err
+		}
+		if err := replayCall(t, c); err != nil {
+			return fmt.Errorf("move %d of %d: %s failed: %v", i+1, len(d.Calls), c.Call, err)
+		}
+	}
+	return wait(d.Waits[len(d.Calls)], canceled)
+}
+
+// wait waits for d, or until canceled is closed.
+func wait(d time.Duration, canceled <-chan struct{}) error {
+	select {
+	case <-canceled:
+		return ErrCanceled
+	default:
+	}
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-canceled:
+		return ErrCanceled
+	case <-timer.C:
+		return nil
+	}
+}
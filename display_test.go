@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlanDrawsNothingOnError(t *testing.T) {
+	turtle := &SVGTurtle{}
+	session := NewSession(turtle, nil, ioutil.Discard, nil)
+	session.Plan = true
+	program, err := ParseString("", "REPEAT 4 [FD 10 RT 90]\nFOO\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Run(program, nil); err == nil {
+		t.Fatalf("Run() = nil, want an error for FOO")
+	}
+	if len(turtle.Strokes) != 0 {
+		t.Errorf("Run() drew %v before the error, want nothing", turtle.Strokes)
+	}
+
+	program, err = ParseString("", "REPEAT 4 [FD 10 RT 90]\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Run(program, nil); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if len(turtle.Strokes) != 1 || len(turtle.Strokes[0].Points) != 5 {
+		t.Errorf("Run() drew %v, want one square", turtle.Strokes)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	steps, inf, up := 10.0, math.Inf(1), true
+	tests := []struct {
+		calls []TurtleCall
+		waits map[int]time.Duration
+		want  string
+	}{
+		{[]TurtleCall{{Call: "move", Steps: &steps}, {Call: "rotate", Degrees: &steps}, {Call: "penup", Up: &up}},
+			map[int]time.Duration{0: time.Second, 3: time.Second}, ""},
+		{[]TurtleCall{{Call: "move", Steps: &inf}}, nil, "move 1 of 1 can't go +Inf steps"},
+		{[]TurtleCall{{Call: "move", Steps: &steps}, {Call: "rotate", Degrees: &inf}}, nil, "move 2 of 2 can't turn +Inf degrees"},
+		{[]TurtleCall{{Call: "move"}}, nil, "move 1 of 1 doesn't say how many steps to go"},
+		{[]TurtleCall{{Call: "rotate", Steps: &steps}}, nil, "move 1 of 1 doesn't say how many degrees to turn"},
+		{[]TurtleCall{{Call: "penup"}}, nil, "move 1 of 1 doesn't say whether the pen goes up"},
+		{[]TurtleCall{{Call: "jump", Steps: &steps}}, nil, `move 1 of 1 is "jump", want move, rotate or penup`},
+		{[]TurtleCall{{Call: "move", Steps: &steps}}, map[int]time.Duration{1: -time.Second}, "can't wait -1s before move 2 of 1"},
+		{[]TurtleCall{{Call: "move", Steps: &steps}}, map[int]time.Duration{2: time.Second}, "a wait of 1s is before move 3, but there are only 1 moves"},
+		{nil, map[int]time.Duration{-1: time.Second}, "a wait of 1s is before move 0, but there are only 0 moves"},
+	}
+	for _, test := range tests {
+		list := &DisplayList{Calls: test.calls, Waits: test.waits}
+		got := ""
+		if err := list.Validate(); err != nil {
+			got = err.Error()
+		}
+		if got != test.want {
+			t.Errorf("Validate(%+v, %v) = %q, want %q", test.calls, test.waits, got, test.want)
+		}
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	list := NewDisplayList(BaseTurtle{})
+	list.Move(10)
+	list.Rotate(-90)
+	list.PenUp(true)
+	list.Move(-5)
+	got := list.Estimate()
+	want := Estimate{Drawn: 10, Travel: 5, Turned: 90, PenChanges: 1,
+		Duration: (10*StepsPerUnit + 90*StepsPerDegree + 1 + 5*StepsPerUnit) * PiStepDelay}
+	if got != want {
+		t.Errorf("Estimate() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPlanWaits(t *testing.T) {
+	turtle := &DisplayList{}
+	session := NewSession(turtle, nil, ioutil.Discard, nil)
+	session.Plan = true
+	var slept []time.Duration
+	session.Sleep = func(d time.Duration) { slept = append(slept, d) }
+	program, err := ParseString("", "PENDOWN SLEEP 20 PENUP SLEEP 30 FD 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := session.Run(program, nil); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if len(slept) != 0 {
+		t.Errorf("Run() slept %v while planning, want the waits played", slept)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Run() took %v, want at least the 50ms it SLEEPs", elapsed)
+	}
+	var calls []string
+	for _, c := range turtle.Calls {
+		calls = append(calls, c.Call)
+	}
+	if got, want := strings.Join(calls, " "), "penup penup move"; got != want {
+		t.Errorf("Run() made calls %s, want %s", got, want)
+	}
+	if session.Sleep(time.Millisecond); len(slept) != 1 {
+		t.Errorf("Run() didn't put Sleep back")
+	}
+}
+
+func TestPlayCanceledWaiting(t *testing.T) {
+	list := NewDisplayList(BaseTurtle{})
+	list.Sleep(time.Hour)
+	list.Move(10)
+	canceled := make(chan struct{})
+	time.AfterFunc(10*time.Millisecond, func() { close(canceled) })
+	turtle := &BaseTurtle{}
+	if err := list.Play(turtle, canceled); err != ErrCanceled {
+		t.Errorf("Play() = %v, want %v", err, ErrCanceled)
+	}
+	if turtle.X != 0 || turtle.Y != 0 {
+		t.Errorf("Play() moved the turtle to (%v, %v) after being canceled", turtle.X, turtle.Y)
+	}
+	if got, want := list.Estimate().Duration, time.Hour+10*StepsPerUnit*PiStepDelay; got != want {
+		t.Errorf("Estimate().Duration = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Error is something that went wrong running a program, at the place in the
+// program where it happened. It is a participle.Error, so it reads like the
+// errors from parsing.
+type Error struct {
+	Pos lexer.Position
+	Msg string
+	// Err is what caused the error, if it came from outside the interpreter.
+	Err error
+}
+
+func (e *Error) Error() string            { return participle.FormatError(e) }
+func (e *Error) Message() string          { return e.Msg }
+func (e *Error) Position() lexer.Position { return e.Pos }
+func (e *Error) Unwrap() error            { return e.Err }
+
+func errorf(pos lexer.Position, format string, args ...interface{}) error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// wrapf is errorf for when err caused the problem. Its message is added to the
+// end of the error's.
+func wrapf(pos lexer.Position, err error, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	var perr participle.Error
+	if errors.As(err, &perr) {
+		return &Error{Pos: perr.Position(), Msg: msg + ": " + perr.Message(), Err: err}
+	}
+	return &Error{Pos: pos, Msg: msg + ": " + err.Error(), Err: err}
+}
+
+// ShowError formats err as file:line:col: message, followed by the line of src
+// it happened on with a caret under the column. src is the source of filename,
+// so an error in another file, like one LOADed or the library, has no line.
+func ShowError(err error, filename, src string) string {
+	var perr participle.Error
+	if !errors.As(err, &perr) {
+		return err.Error()
+	}
+	pos := perr.Position()
+	out := perr.Error()
+	lines := strings.Split(src, "\n")
+	if pos.Filename != filename || pos.Line < 1 || pos.Line > len(lines) {
+		return out
+	}
+	line := strings.TrimRight(lines[pos.Line-1], "\r")
+	// Keep tabs, so the caret lines up however wide they are.
+	indent := []rune{}
+	for i, c := range []rune(line) {
+		if i >= pos.Column-1 {
+			break
+		}
+		if c != '\t' {
+			c = ' '
+		}
+		indent = append(indent, c)
+	}
+	return out + "\n" + line + "\n" + string(indent) + "^"
+}
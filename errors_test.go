@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"fd \"abc\n", "test:1:4: fd doesn't like abc as input\nfd \"abc\n   ^"},
+		{"print 1 / 0\n", "test:1:9: / can't divide by zero\nprint 1 / 0\n        ^"},
+		{"to sq\n\tfowardd 1\nend\nsq\n", "test:2:2: I don't know how to fowardd\n\tfowardd 1\n\t^"},
+		{"output 1\n", "test:1:1: OUTPUT can only be used inside a procedure\noutput 1\n^"},
+	}
+	for _, test := range tests {
+		program, err := ParseString("test", test.src)
+		if err != nil {
+			t.Fatalf("Parse(%q) = %v", test.src, err)
+		}
+		ctx := NewContext(NewTextTurtle(ioutil.Discard), nil, ioutil.Discard, nil)
+		err = ctx.RunProgram(program)
+		var rerr *Error
+		if !errors.As(err, &rerr) {
+			t.Fatalf("Run(%q) = %v, want an *Error", test.src, err)
+		}
+		if got := ShowError(err, "test", test.src); got != test.want {
+			t.Errorf("Run(%q) error =\n%s\nwant\n%s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestErrorInOtherFile(t *testing.T) {
+	err := errorf(lexer.Position{Filename: "other", Line: 1, Column: 1}, "oops")
+	// Line 1 of test isn't where the error is, so it isn't shown.
+	if got := ShowError(err, "test", "fd 1\n"); got != "other:1:1: oops" {
+		t.Errorf("ShowError = %q, want just the error", got)
+	}
+}
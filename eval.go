@@ -1,10 +1,13 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"io"
+	"strings"
 	"time"
 
-	"github.com/alecthomas/repr"
+	"github.com/alecthomas/participle/v2/lexer"
 )
 
 type Evaluatable interface {
@@ -22,107 +25,395 @@ type TurtleController interface {
 	Rotate(deg float64) (heading float64, err error)
 	// PenUp sets the state of the pen to state. PenUp(true) to stop drawing, PenUp(false) to start again
 	PenUp(state bool) (bool, error)
+	// State returns the position, heading and pen of the turtle.
+	State() BaseTurtle
 }
 
 // Context for evaluation.
 type Context struct {
-	// User-provided functions.
+	// User-provided functions, callable by name like primitives. They take
+	// no inputs unless called in parentheses, as in (DISTANCE 3 4).
 	Functions map[string]Function
-	// Vars defined during evaluation.
+	// Global vars defined during evaluation.
 	Vars map[string]interface{}
+	// Procedures defined with TO, keyed by upper case name.
+	Procedures map[string]*To
+	// Procedures there from the start, which those defined with TO replace.
+	Library map[string]*To
 	// Turtle for drawing
 	Turtle TurtleController
-	// Reader from which INPUT is read.
+	// Reader from which READWORD and friends read.
 	Input io.Reader
 	// Writer where PRINTing will write.
 	Output io.Writer
+	// Canceled is closed to stop the program at the next instruction.
+	Canceled <-chan struct{}
+	// Random numbers for RANDOM, PICK and SHUFFLE.
+	Random *Random
+	// Directories LOAD looks in after the one the program is in.
+	SearchPath []string
+	// Sleep waits for SLEEP.
+	Sleep func(time.Duration)
+
+	// Local vars of the procedures being run, innermost last.
+	frames []map[string]interface{}
+	// Instructions the procedure being called takes its inputs from.
+	stream *stream
+	// Iteration of the innermost REPEAT.
+	repcount int
+	// Inputs of the templates being run, innermost last.
+	slots [][]interface{}
+	// Input, buffered once something reads from it.
+	input *bufio.Reader
+	// The error most recently caught by CATCH "ERROR, for ERROR.
+	lastError error
+	// Height of the capitals LABEL draws.
+	labelHeight float64
+	// Turtles saved by PUSHTURTLE, innermost last.
+	turtles []BaseTurtle
 }
 
-func RunCommandList(commands []Command, ctx *Context) error {
-	for index := 0; index < len(commands); {
-		cmd := commands[index]
-		//fmt.Fprintf(ctx.Output, "Got Cmd: %+v\n", cmd.Command)
-		switch {
-		case cmd.Sleep != nil:
-			cmd := cmd.Sleep
-			value, err := cmd.Expression.Evaluate(ctx)
-			if err != nil {
-				return err
-			}
-			time.Sleep(time.Millisecond * time.Duration(value.(float64)))
-		case cmd.Forward != nil:
-			cmd := cmd.Forward
-			value, err := cmd.Expression.Evaluate(ctx)
-			if err != nil {
-				return err
-			}
-			ctx.Turtle.Move(value.(float64))
-		case cmd.Backward != nil:
-			cmd := cmd.Backward
-			value, err := cmd.Expression.Evaluate(ctx)
-			if err != nil {
-				return err
-			}
-			ctx.Turtle.Move(-value.(float64))
-			//ctx.Vars[cmd.Variable] = value
-		case cmd.Right != nil:
-			cmd := cmd.Right
-			value, err := cmd.Expression.Evaluate(ctx)
-			if err != nil {
-				return err
-			}
-			ctx.Turtle.Rotate(-value.(float64))
-		case cmd.Left != nil:
-			cmd := cmd.Left
-			value, err := cmd.Expression.Evaluate(ctx)
-			if err != nil {
-				return err
-			}
-			ctx.Turtle.Rotate(value.(float64))
-		case cmd.PenUp != nil:
-			_, err := ctx.Turtle.PenUp(true)
-			if err != nil {
-				return err
+func NewContext(turtle TurtleController, r io.Reader, w io.Writer, functions map[string]Function) *Context {
+	return &Context{
+		Vars:        map[string]interface{}{},
+		Procedures:  map[string]*To{},
+		Library:     library,
+		Functions:   functions,
+		Input:       r,
+		Output:      w,
+		Turtle:      turtle,
+		Random:      NewRandom(time.Now().UnixNano()),
+		Sleep:       time.Sleep,
+		repcount:    -1,
+		labelHeight: 10,
+	}
+}
+
+// stream hands out the expressions of a line or list one at a time, so that
+// each procedure call can take as many of them as it has inputs.
+type stream struct {
+	items []*Expression
+	next  int
+}
+
+func (s *stream) more() bool {
+	return s != nil && s.next < len(s.items)
+}
+
+func (s *stream) peek() *Expression {
+	return s.items[s.next]
+}
+
+// ErrCanceled is returned when a program stops because Canceled was closed.
+var ErrCanceled = errors.New("canceled")
+
+// checkCanceled returns ErrCanceled once the program has been canceled.
+func (ctx *Context) checkCanceled() error {
+	select {
+	case <-ctx.Canceled:
+		return ErrCanceled
+	default:
+		return nil
+	}
+}
+
+// stopSignal unwinds to the procedure that ran STOP.
+type stopSignal struct {
+	Pos lexer.Position
+}
+
+func (s *stopSignal) Error() string {
+	return errorf(s.Pos, "STOP can only be used inside a procedure").Error()
+}
+
+// outputSignal carries the input of OUTPUT back to the procedure call.
+type outputSignal struct {
+	Pos   lexer.Position
+	Value interface{}
+}
+
+func (s *outputSignal) Error() string {
+	return errorf(s.Pos, "OUTPUT can only be used inside a procedure").Error()
+}
+
+// evalNext evaluates the next expression of s, letting any procedure it calls
+// take its inputs from the expressions after it.
+func (ctx *Context) evalNext(s *stream) (interface{}, error) {
+	e := s.items[s.next]
+	s.next++
+	saved := ctx.stream
+	ctx.stream = s
+	defer func() { ctx.stream = saved }()
+	return e.Evaluate(ctx)
+}
+
+// call runs the procedure name with n inputs taken from s, or as many as it
+// usually takes if n is negative.
+func (ctx *Context) call(pos lexer.Position, name string, s *stream, n int) (interface{}, error) {
+	if n < 0 {
+		key := strings.ToUpper(name)
+		if proc, ok := ctx.procedure(key); ok {
+			n = len(proc.Params)
+		} else if prim, ok := primitives[key]; ok {
+			n = prim.Inputs
+		} else if _, ok := ctx.function(name); ok {
+			n = 0
+		} else {
+			return nil, errorf(pos, "I don't know how to %s", name)
+		}
+	}
+	args, argPos, err := ctx.gather(pos, name, s, n)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.invoke(pos, name, args, argPos)
+}
+
+// apply runs the procedure name with inputs that have already been evaluated.
+func (ctx *Context) apply(pos lexer.Position, name string, args []interface{}) (interface{}, error) {
+	return ctx.invoke(pos, name, args, nil)
+}
+
+// invoke is apply with the positions of the inputs in the program, if they
+// came from there, so that errors can point at the input that was wrong.
+func (ctx *Context) invoke(pos lexer.Position, name string, args []interface{}, argPos []lexer.Position) (interface{}, error) {
+	key := strings.ToUpper(name)
+	min, max := 0, 0
+	proc, isProc := ctx.procedure(key)
+	prim, isPrim := primitives[key]
+	switch {
+	case isProc:
+		min, max = len(proc.Params), len(proc.Params)
+	case isPrim:
+		min, max = prim.MinInputs, prim.MaxInputs
+	default:
+		fn, ok := ctx.function(name)
+		if !ok {
+			return nil, errorf(pos, "I don't know how to %s", name)
+		}
+		value, err := fn(args...)
+		if err != nil {
+			return nil, wrapf(pos, err, "%s failed", name)
+		}
+		return value, nil
+	}
+	if len(args) < min {
+		return nil, errorf(pos, "not enough inputs to %s", name)
+	}
+	if max >= 0 && len(args) > max {
+		return nil, errorf(pos, "too many inputs to %s", name)
+	}
+	if isProc {
+		return ctx.runProcedure(proc, args)
+	}
+	return prim.Fn(ctx, &Inputs{Pos: pos, Name: name, Values: args, Positions: argPos})
+}
+
+// function finds a user-provided function, ignoring case.
+func (ctx *Context) function(name string) (Function, bool) {
+	if fn, ok := ctx.Functions[name]; ok {
+		return fn, true
+	}
+	for key, fn := range ctx.Functions {
+		if strings.EqualFold(key, name) {
+			return fn, true
+		}
+	}
+	return nil, false
+}
+
+// gather evaluates n inputs for name from s, or everything left in s if n is
+// negative.
+func (ctx *Context) gather(pos lexer.Position, name string, s *stream, n int) ([]interface{}, []lexer.Position, error) {
+	var args []interface{}
+	var argPos []lexer.Position
+	for n < 0 && s.more() || len(args) < n {
+		if !s.more() {
+			return nil, nil, errorf(pos, "not enough inputs to %s", name)
+		}
+		item := s.peek()
+		value, err := ctx.evalNext(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		if value == nil {
+			if call := item.call(); call != nil {
+				return nil, nil, errorf(item.Pos, "%s didn't output to %s", call.Name, name)
 			}
-		case cmd.PenDown != nil:
-			_, err := ctx.Turtle.PenUp(false)
-			if err != nil {
+			return nil, nil, errorf(item.Pos, "nothing to input to %s", name)
+		}
+		args = append(args, value)
+		argPos = append(argPos, item.Pos)
+	}
+	return args, argPos, nil
+}
+
+func (ctx *Context) runProcedure(proc *To, args []interface{}) (interface{}, error) {
+	frame := map[string]interface{}{}
+	for i, param := range proc.Params {
+		frame[strings.ToLower(param)] = args[i]
+	}
+	ctx.frames = append(ctx.frames, frame)
+	defer func() { ctx.frames = ctx.frames[:len(ctx.frames)-1] }()
+
+	err := ctx.RunLines(proc.Body)
+	switch signal := err.(type) {
+	case *stopSignal:
+		return nil, nil
+	case *outputSignal:
+		return signal.Value, nil
+	}
+	return nil, err
+}
+
+// define makes proc callable, replacing any procedure of the same name.
+func (ctx *Context) define(proc *To) error {
+	key := strings.ToUpper(proc.Name)
+	if _, ok := primitives[key]; ok {
+		return errorf(proc.Pos, "%s is a primitive", proc.Name)
+	}
+	ctx.Procedures[key] = proc
+	return nil
+}
+
+// lookup finds the value of a variable, searching the procedures being run
+// from the innermost out and then the globals.
+func (ctx *Context) lookup(name string) (interface{}, bool) {
+	key := strings.ToLower(name)
+	for i := len(ctx.frames) - 1; i >= 0; i-- {
+		if value, ok := ctx.frames[i][key]; ok {
+			return value, value != nil
+		}
+	}
+	value, ok := ctx.Vars[key]
+	return value, ok
+}
+
+// setVar assigns to the innermost variable called name, creating a global if
+// there is none.
+func (ctx *Context) setVar(name string, value interface{}) {
+	key := strings.ToLower(name)
+	for i := len(ctx.frames) - 1; i >= 0; i-- {
+		if _, ok := ctx.frames[i][key]; ok {
+			ctx.frames[i][key] = value
+			return
+		}
+	}
+	ctx.Vars[key] = value
+}
+
+// declareLocal creates a variable without a value in the current procedure.
+func (ctx *Context) declareLocal(name string) {
+	key := strings.ToLower(name)
+	if len(ctx.frames) == 0 {
+		return
+	}
+	frame := ctx.frames[len(ctx.frames)-1]
+	if _, ok := frame[key]; !ok {
+		frame[key] = nil
+	}
+}
+
+// RunLines runs each line in turn, defining any procedures along the way.
+func (ctx *Context) RunLines(lines []*Line) error {
+	for _, line := range lines {
+		if line.To != nil {
+			if err := ctx.define(line.To); err != nil {
 				return err
 			}
-		case cmd.Repeat != nil:
-			cmd := cmd.Repeat
-			value, err := cmd.Times.Evaluate(ctx)
-			if err != nil {
+			continue
+		}
+		if _, err := ctx.RunList(line.Items, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunList runs items as instructions. If reporter is set, a value output by
+// the last instruction is returned, so that IF and the like can be used as
+// reporters. Any other value nobody used is an error.
+func (ctx *Context) RunList(items []*Expression, reporter bool) (interface{}, error) {
+	s := &stream{items: items}
+	for s.more() {
+		if err := ctx.checkCanceled(); err != nil {
+			return nil, err
+		}
+		item := s.peek()
+		value, err := ctx.evalNext(s)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			continue
+		}
+		if reporter && !s.more() {
+			return value, nil
+		}
+		return nil, errorf(item.Pos, "You don't say what to do with %s", FormatValue(value))
+	}
+	return nil, nil
+}
+
+// RunValue runs input i, which must be a list, such as the body of a REPEAT.
+func (ctx *Context) RunValue(in *Inputs, i int, reporter bool) (interface{}, error) {
+	list, err := in.List(i)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.runList(in.Pos, list, reporter)
+}
+
+// runList runs a list as instructions.
+func (ctx *Context) runList(pos lexer.Position, list List, reporter bool) (interface{}, error) {
+	items, err := listExpressions(pos, list)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.RunList(items, reporter)
+}
+
+// listExpressions returns the expressions a list is made of. Lists built while
+// the program runs have no source, so they are turned back into text and
+// parsed first.
+func listExpressions(pos lexer.Position, list List) ([]*Expression, error) {
+	if list.Source != nil {
+		return list.Source.Items, nil
+	}
+	src := formatItems(list.Items)
+	program := &Program{}
+	if err := basicParser.ParseString(pos.Filename, "["+src+"]\n", program); err != nil {
+		return nil, errorf(pos, "can't run %s: %s", FormatValue(list), err)
+	}
+	return program.Lines[0].Items[0].Left.Left.Left.Base.List.Items, nil
+}
+
+// RunProgram defines every procedure in p and then runs its instructions.
+func (ctx *Context) RunProgram(p *Program) error {
+	for _, line := range p.Lines {
+		if line.To != nil {
+			if err := ctx.define(line.To); err != nil {
 				return err
 			}
-			for i := 0.0; i < value.(float64); i++ {
-				err := RunCommandList(cmd.Commands, ctx)
-				if err != nil {
-					return err
-				}
-			}
-		//fmt.Fprintf(ctx.Output, "repeat %v\n",
-		case cmd.Comment != nil:
-		default:
-			panic("unsupported command " + repr.String(cmd))
 		}
-
-		index++
 	}
-	return nil
+	err := ctx.RunLines(p.Lines)
+	switch signal := err.(type) {
+	case *stopSignal:
+		return nil
+	case *outputSignal:
+		return errorf(signal.Pos, "OUTPUT can only be used inside a procedure")
+	case *throwSignal:
+		return errorf(signal.Pos, "Can't find catch tag for %s", signal.Tag)
+	}
+	return err
 }
 
 func (p *Program) Evaluate(turtle Turtle, r io.Reader, w io.Writer, functions map[string]Function) error {
-	if len(p.Commands) == 0 {
+	if len(p.Lines) == 0 {
 		return nil
 	}
 
-	ctx := &Context{
-		Vars:      map[string]interface{}{},
-		Functions: functions,
-		Input:     r,
-		Output:    w,
-		Turtle:    turtle,
-	}
-	return RunCommandList(p.Commands, ctx)
+	ctx := NewContext(turtle, r, w, functions)
+	return ctx.RunProgram(p)
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// result runs src on a text turtle and returns what it prints followed by
+// what its last line outputs, the way SHOW would show it.
+func result(src string) (string, error) {
+	return resultReading(src, "")
+}
+
+// resultReading is result for a program that reads input.
+func resultReading(src, input string) (string, error) {
+	var out bytes.Buffer
+	ctx := NewContext(NewTextTurtle(ioutil.Discard), strings.NewReader(input), &out, nil)
+	value, err := resultIn(ctx, src)
+	return out.String() + value, err
+}
+
+// resultIn runs src in ctx and returns what its last line outputs, or
+// nothing if it doesn't output anything.
+func resultIn(ctx *Context, src string) (string, error) {
+	program, err := ParseString("", src)
+	if err != nil {
+		return "", err
+	}
+	lines := program.Lines
+	if len(lines) == 0 || lines[len(lines)-1].To != nil {
+		return "", ctx.RunProgram(program)
+	}
+	program.Lines = lines[:len(lines)-1]
+	if err := ctx.RunProgram(program); err != nil {
+		return "", err
+	}
+	value, err := ctx.RunList(lines[len(lines)-1].Items, true)
+	if err != nil || value == nil {
+		return "", err
+	}
+	return FormatValue(value), nil
+}
+
+// resultTest is a program and the result it should give, or the error it
+// should stop with.
+type resultTest struct {
+	src, want string
+}
+
+// runResults checks the result of each test's program.
+func runResults(t *testing.T, tests []resultTest) {
+	t.Helper()
+	runReading(t, "", tests)
+}
+
+// runReading checks the result of each test's program when it reads input.
+func runReading(t *testing.T, input string, tests []resultTest) {
+	t.Helper()
+	for _, test := range tests {
+		got, err := resultReading(test.src, input)
+		if err != nil {
+			t.Errorf("Run(%q) = %v", test.src, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Run(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+// runErrors checks the error each test's program stops with.
+func runErrors(t *testing.T, tests []resultTest) {
+	t.Helper()
+	for _, test := range tests {
+		_, err := result(test.src)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("Run(%q) = %v, want %s", test.src, err, test.want)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	runResults(t, []resultTest{
+		{"1 + 2 * 3", "7"},
+		{"(1 + 2) * 3", "9"},
+		{"2 * 2 ^ 3", "16"},
+		{"7 - 2 - 1", "4"},
+		{"1 < 2", "true"},
+		{"\"abc", "abc"},
+		{"[FD 10 [RT :x]]", "[FD 10 [RT :x]]"},
+		{"MAKE \"x 3\n:x * :x", "9"},
+		{"MAKE \"x 3 MAKE \"x :x + 1 THING \"x", "4"},
+		{"NAME 3 \"x NAME :x + 1 \"x THING \"x", "4"},
+		{"MAKE \"x 1 (LIST NAME? \"x NAMEP \"y)", "[true false]"},
+		{"TO SQ :n\nOUTPUT :n * :n\nEND\nSQ SQ 2", "16"},
+		{"TO F :n\nIF :n > 2 [OUTPUT :n]\nOUTPUT F :n + 1\nEND\nF 0", "3"},
+		{"TO G\nMAKE \"x 1\nSTOP\nMAKE \"x 2\nEND\nG :x", "1"},
+		// LOCAL variables and inputs hide the globals of the same name.
+		{"MAKE \"x 1\nTO H :x\nLOCAL \"y MAKE \"y :x MAKE \"x 5\nEND\nH 2 :x", "1"},
+		{"MAKE \"n 0 REPEAT 3 [MAKE \"n :n + REPCOUNT] :n", "6"},
+		{"IF 1 = 1 [\"yes]", "yes"},
+		{"IFELSE 1 = 2 [\"yes] [\"no]", "no"},
+		{"FD 10 RT 90 FD 5 POS", "[5 10]"},
+		{"SETXY 3 4 SETHEADING 45 HEADING", "45"},
+	})
+}
+
+func TestEvalErrors(t *testing.T) {
+	runErrors(t, []resultTest{
+		{"FOO", "1:1: I don't know how to FOO"},
+		{":x", "1:1: x has no value"},
+		{"FD", "1:1: not enough inputs to FD"},
+		{"1 2", "1:1: You don't say what to do with 1"},
+		{"TO F :a\nEND\nF", "3:1: not enough inputs to F"},
+		{"TO FD\nEND", "1:1: FD is a primitive"},
+		{"OUTPUT 1", "1:1: OUTPUT can only be used inside a procedure"},
+	})
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Format prints p in canonical form: procedure names in upper case, one space
+// between things, spaces around infix operators, procedure bodies and lists
+// that span lines indented by two spaces, and comments where they were.
+// Lists that are data are left as they were written. If expand is set,
+// abbreviations like FD are written out in full.
+func Format(p *Program, expand bool) string {
+	f := &formatter{
+		comments: p.Comments,
+		code:     codeLists(p),
+		expand:   expand,
+		atStart:  true,
+	}
+	for _, line := range p.Lines {
+		if line.To != nil {
+			f.to(line.To)
+		} else {
+			f.line(line.Pos, line.Items)
+		}
+	}
+	f.flushComments(-1)
+	return f.b.String()
+}
+
+type formatter struct {
+	b strings.Builder
+	// Comments not yet written, in order.
+	comments []*Comment
+	code     map[*ListLiteral]bool
+	expand   bool
+
+	indent int
+	// Whether what's being written is instructions rather than data.
+	inCode bool
+	// Source line of the last thing written.
+	srcLine int
+	atStart bool
+	// Whether the next token sticks to the one before, as after "[".
+	attach bool
+}
+
+// write adds a token from source line pos.Line.
+func (f *formatter) write(pos lexer.Position, text string) {
+	f.flushComments(pos.Line)
+	switch {
+	case f.atStart:
+		f.b.WriteString(strings.Repeat("  ", f.indent))
+	case !f.attach:
+		f.b.WriteString(" ")
+	}
+	f.b.WriteString(text)
+	f.atStart, f.attach = false, false
+	if pos.Line > f.srcLine {
+		f.srcLine = pos.Line
+	}
+}
+
+// newline ends the output line, along with any comment that ended the source
+// line.
+func (f *formatter) newline() {
+	if f.atStart {
+		return
+	}
+	for len(f.comments) > 0 && f.comments[0].Pos.Line <= f.srcLine {
+		f.b.WriteString(" " + f.comments[0].Text)
+		f.comments = f.comments[1:]
+	}
+	f.b.WriteString("\n")
+	f.atStart, f.attach = true, false
+}
+
+// start begins a new line of instructions from source line pos.Line, keeping
+// a blank line before it if there was one.
+func (f *formatter) start(pos lexer.Position) {
+	f.newline()
+	f.flushComments(pos.Line)
+	f.blankLine(pos.Line)
+}
+
+func (f *formatter) blankLine(line int) {
+	if f.b.Len() > 0 && line > f.srcLine+1 {
+		f.b.WriteString("\n")
+	}
+}
+
+// flushComments writes the comments on their own lines before source line
+// line, or all of them if line is negative.
+func (f *formatter) flushComments(line int) {
+	for len(f.comments) > 0 && (line < 0 || f.comments[0].Pos.Line < line) {
+		c := f.comments[0]
+		if c.Pos.Line <= f.srcLine && !f.atStart {
+			// It ended a line that's still being written.
+			f.newline()
+			continue
+		}
+		f.newline()
+		f.blankLine(c.Pos.Line)
+		f.b.WriteString(strings.Repeat("  ", f.indent) + c.Text + "\n")
+		f.srcLine = c.Pos.Line
+		f.comments = f.comments[1:]
+	}
+}
+
+func (f *formatter) to(to *To) {
+	f.start(to.Pos)
+	f.inCode = true
+	f.write(to.Pos, "TO "+strings.ToUpper(to.Name))
+	for _, param := range to.Params {
+		f.b.WriteString(" :" + param)
+	}
+	f.indent++
+	for _, line := range to.Body {
+		if line.To != nil {
+			f.to(line.To)
+		} else {
+			f.line(line.Pos, line.Items)
+		}
+	}
+	f.indent--
+	f.start(to.EndPos)
+	f.write(to.EndPos, "END")
+	f.newline()
+}
+
+func (f *formatter) line(pos lexer.Position, items []*Expression) {
+	f.start(pos)
+	f.inCode = true
+	for _, item := range items {
+		f.expression(item)
+	}
+	f.newline()
+}
+
+func (f *formatter) expression(e *Expression) {
+	f.cmp(e.Left)
+	for _, right := range e.Right {
+		f.write(right.Pos, string(right.Operator))
+		f.cmp(right.Cmp)
+	}
+}
+
+func (f *formatter) cmp(c *Cmp) {
+	f.term(c.Left)
+	for _, right := range c.Right {
+		f.write(right.Pos, string(right.Operator))
+		f.term(right.Term)
+	}
+}
+
+func (f *formatter) term(t *Term) {
+	f.factor(t.Left)
+	for _, right := range t.Right {
+		f.write(right.Pos, string(right.Operator))
+		f.factor(right.Factor)
+	}
+}
+
+func (f *formatter) factor(factor *Factor) {
+	f.value(factor.Base)
+	if factor.Exponent != nil {
+		f.write(factor.Exponent.Pos, "^")
+		f.value(factor.Exponent)
+	}
+}
+
+// formatNumber writes a number literal so that it reads back as exactly the
+// same number. Unlike FormatValue it doesn't round anything away.
+func formatNumber(n float64) string {
+	if n == math.Trunc(n) && math.Abs(n) < 1e21 {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
+
+func (f *formatter) value(v *Value) {
+	switch {
+	case v.Number != nil:
+		f.write(v.Pos, formatNumber(*v.Number))
+	case v.Word != nil:
+		f.write(v.Pos, quoteWord(*v.Word))
+	case v.Text != nil:
+		f.write(v.Pos, *v.Text)
+	case v.Variable != nil:
+		f.write(v.Pos, ":"+*v.Variable)
+	case v.List != nil:
+		f.list(v.List)
+	case v.Subexpression != nil:
+		f.write(v.Pos, "(")
+		f.attach = true
+		for _, item := range v.Subexpression.Items {
+			f.expression(item)
+		}
+		f.attach = false
+		f.b.WriteString(")")
+	case v.Negated != nil:
+		f.write(v.Pos, "-")
+		f.attach = true
+		f.value(v.Negated)
+	case v.Call != nil:
+		f.write(v.Pos, f.name(v.Call.Name))
+	}
+}
+
+// name is how a procedure call is written: in upper case, and in full if
+// expand is set. Words in lists of data are left alone.
+func (f *formatter) name(name string) string {
+	if !f.inCode {
+		return name
+	}
+	name = strings.ToUpper(name)
+	if full, ok := fullNames[name]; ok && f.expand {
+		return full
+	}
+	return name
+}
+
+// list writes a list on one line if it was written on one line, and otherwise
+// with each line of it indented.
+func (f *formatter) list(l *ListLiteral) {
+	saved := f.inCode
+	defer func() { f.inCode = saved }()
+	f.inCode = saved && f.code[l]
+
+	f.write(l.Pos, "[")
+	if l.Pos.Line == l.EndPos.Line {
+		f.attach = true
+		for _, item := range l.Items {
+			f.expression(item)
+		}
+		f.attach = false
+		f.b.WriteString("]")
+		return
+	}
+	f.indent++
+	for i, item := range l.Items {
+		if i == 0 || item.Pos.Line != l.Items[i-1].Pos.Line {
+			f.newline()
+		}
+		f.expression(item)
+	}
+	f.indent--
+	f.newline()
+	f.write(l.EndPos, "]")
+}
+
+// FormatProcedures prints procedures in canonical form, one after the other,
+// as POPS and SAVE write them.
+func FormatProcedures(procs []*To, expand bool) string {
+	p := &Program{}
+	for _, proc := range procs {
+		p.Lines = append(p.Lines, &Line{Pos: proc.Pos, To: proc})
+	}
+	code := codeLists(p)
+	var b strings.Builder
+	for i, proc := range procs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		f := &formatter{code: code, expand: expand, atStart: true}
+		f.to(proc)
+		b.WriteString(f.b.String())
+	}
+	return b.String()
+}
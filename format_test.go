@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	src := `; draws things
+
+to  square :size ; a square
+repeat 4 [fd :size rt 90]
+
+
+repeat 2 [fd 1
+; turn
+rt 90 ; right
+]
+end
+make "x(sum 1 2)*-3
+print [fd hello [a b]] ; data
+print [Hello,  world!] # greet
+print "|a [b]| "|c|
+square :x+1
+if :x>1[pu]
+`
+	want := `; draws things
+
+TO SQUARE :size ; a square
+  REPEAT 4 [FORWARD :size RIGHT 90]
+
+  REPEAT 2 [
+    FORWARD 1
+    ; turn
+    RIGHT 90 ; right
+  ]
+END
+MAKE "x (SUM 1 2) * -3
+PRINT [fd hello [a b]] ; data
+PRINT [Hello, world!] # greet
+PRINT "|a [b]| "c
+SQUARE :x + 1
+IF :x > 1 [PENUP]
+`
+	program, err := ParseString("", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Format(program, true)
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	program, err = ParseString("", got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again := Format(program, true); again != got {
+		t.Errorf("formatting again changed it to\n%s", again)
+	}
+}
+
+func TestFormatNumbers(t *testing.T) {
+	src := "FD 0.00000000001 FD 0.1 FD 0.3333333333333333 FD 1000000 FD 1e20 FD 1e21 FD 2.5e-300 FD 123456789.123456789\n"
+	program, err := ParseString("", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted := Format(program, true)
+	again, err := ParseString("", formatted)
+	if err != nil {
+		t.Fatalf("Parse(%q) = %v", formatted, err)
+	}
+	want, got := program.Lines[0].Items, again.Lines[0].Items
+	if len(got) != len(want) {
+		t.Fatalf("Format(%q) = %q, which has %d items, want %d", src, formatted, len(got), len(want))
+	}
+	for i := range want {
+		if n := want[i].value(); n != nil && n.Number != nil {
+			if m := got[i].value(); m == nil || m.Number == nil || *m.Number != *n.Number {
+				t.Errorf("Format(%q) = %q, which doesn't read back as %v", src, formatted, *n.Number)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// ErrEndOfInput is what the READ primitives fail with once Context.Input has
+// nothing left, so a program can CATCH it and stop asking.
+var ErrEndOfInput = errors.New("end of input")
+
+// reader buffers ctx.Input, so that READCHAR can leave the rest of a line for
+// the next READWORD.
+func (ctx *Context) reader() *bufio.Reader {
+	if ctx.input == nil {
+		input := ctx.Input
+		if input == nil {
+			input = strings.NewReader("")
+		}
+		ctx.input = bufio.NewReader(input)
+	}
+	return ctx.input
+}
+
+// readLine reads the next line of input without its line ending.
+func (ctx *Context) readLine(in *Inputs) (string, error) {
+	line, err := ctx.reader().ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", ctx.readError(in, err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (ctx *Context) readError(in *Inputs, err error) error {
+	if err == io.EOF {
+		return wrapf(in.Pos, ErrEndOfInput, "%s", in.Name)
+	}
+	return wrapf(in.Pos, err, "%s can't read input", in.Name)
+}
+
+// splitList turns a line typed in by the operator into a list, with
+// brackets making sublists. Everything else is a word, even "hello,".
+func splitList(line string) (List, bool) {
+	stack := []*List{{}}
+	word := strings.Builder{}
+	flush := func() {
+		if word.Len() > 0 {
+			top := stack[len(stack)-1]
+			top.Items = append(top.Items, word.String())
+			word.Reset()
+		}
+	}
+	for _, c := range line {
+		switch {
+		case unicode.IsSpace(c):
+			flush()
+		case c == '[':
+			flush()
+			stack = append(stack, &List{})
+		case c == ']':
+			flush()
+			if len(stack) == 1 {
+				return List{}, false
+			}
+			inner := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			top := stack[len(stack)-1]
+			top.Items = append(top.Items, NewList(inner.Items...))
+		default:
+			word.WriteRune(c)
+		}
+	}
+	flush()
+	if len(stack) != 1 {
+		return List{}, false
+	}
+	return NewList(stack[0].Items...), true
+}
+
+func init() {
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		return ctx.readLine(in)
+	}), "READWORD", "RW")
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		line, err := ctx.readLine(in)
+		if err != nil {
+			return nil, err
+		}
+		list, ok := splitList(line)
+		if !ok {
+			return nil, in.errorf("%s can't make a list of %s, the brackets don't match", in.Name, line)
+		}
+		return list, nil
+	}), "READLIST", "RL")
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		c, _, err := ctx.reader().ReadRune()
+		if err != nil {
+			return nil, ctx.readError(in, err)
+		}
+		return string(c), nil
+	}), "READCHAR", "RC")
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	runReading(t, "hello world\n", []resultTest{
+		{"READWORD", "hello world"},
+		{"READLIST", "[hello world]"},
+		{"READCHAR", "h"},
+	})
+	runReading(t, "Hello, [big] world!\r\n", []resultTest{
+		{"RL", "[Hello, [big] world!]"},
+	})
+	runReading(t, "a\n\nb", []resultTest{
+		{"SHOW RL SHOW RL RL", "[a]\n[]\n[b]"},
+	})
+	runReading(t, "abc\nd\n", []resultTest{
+		{"PRINT RC PRINT RC RW", "a\nb\nc"},
+	})
+	runReading(t, "1\n2\n", []resultTest{
+		{"SUM RW RW", "3"},
+	})
+}
+
+func TestReadErrors(t *testing.T) {
+	if _, err := resultReading("RW", ""); !errors.Is(err, ErrEndOfInput) {
+		t.Errorf("Run(RW) with no input = %v, want %v", err, ErrEndOfInput)
+	}
+	want := "1:7: READLIST can't make a list of a ]b, the brackets don't match"
+	if _, err := resultReading("PRINT READLIST", "a ]b\n"); err == nil || err.Error() != want {
+		t.Errorf("Run(PRINT READLIST) = %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//go:embed hershey.txt
+var hersheySource string
+
+// glyph is a character of a single-stroke font, in font units.
+type glyph struct {
+	width   float64
+	strokes [][]Point
+}
+
+// capHeight is how tall capitals are in the font, which LABEL's height is.
+const capHeight = 21
+
+var font = parseFont(hersheySource)
+
+func parseFont(src string) map[rune]glyph {
+	glyphs := map[rune]glyph{}
+	for n, line := range strings.Split(src, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 3)
+		code, err := strconv.Atoi(fields[0])
+		if err != nil {
+			panic(fmt.Sprintf("hershey.txt:%d: bad character code %q", n+1, fields[0]))
+		}
+		width, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			panic(fmt.Sprintf("hershey.txt:%d: bad width %q", n+1, fields[1]))
+		}
+		g := glyph{width: width}
+		if len(fields) == 3 {
+			for _, stroke := range strings.Split(fields[2], "|") {
+				var points []Point
+				for _, xy := range strings.Fields(stroke) {
+					var p Point
+					if _, err := fmt.Sscanf(xy, "%g,%g", &p.X, &p.Y); err != nil {
+						panic(fmt.Sprintf("hershey.txt:%d: bad point %q", n+1, xy))
+					}
+					points = append(points, p)
+				}
+				g.strokes = append(g.strokes, points)
+			}
+		}
+		glyphs[rune(code)] = g
+	}
+	return glyphs
+}
+
+// label draws text with its baseline starting at the turtle and running
+// along its heading, using only Move, Rotate and PenUp. The turtle ends up
+// where the next character would start, facing the way it was, with the pen
+// as it was.
+func (ctx *Context) label(text string, height float64) error {
+	t := ctx.Turtle
+	start := t.State()
+	x0, y0 := LogoPos(start)
+	heading := LogoHeading(start)
+	scale := height / capHeight
+	sin, cos := math.Sincos(heading * math.Pi / 180)
+	// at is where font point p of a character starting at advance lands.
+	at := func(advance float64, p Point) (float64, float64) {
+		along, up := (advance+p.X)*scale, p.Y*scale
+		return x0 + along*sin - up*cos, y0 + along*cos + up*sin
+	}
+
+	advance := 0.0
+	for _, r := range text {
+		if err := ctx.checkCanceled(); err != nil {
+			return err
+		}
+		g, ok := font[r]
+		if !ok {
+			g = font['?']
+		}
+		for _, stroke := range g.strokes {
+			for i, p := range stroke {
+				if err := setPen(t, i == 0); err != nil {
+					return err
+				}
+				x, y := at(advance, p)
+				if err := lineTo(t, x, y); err != nil {
+					return err
+				}
+			}
+		}
+		advance += g.width
+	}
+
+	if err := setPen(t, true); err != nil {
+		return err
+	}
+	x, y := at(advance, Point{})
+	if err := lineTo(t, x, y); err != nil {
+		return err
+	}
+	if err := SetHeading(t, heading); err != nil {
+		return err
+	}
+	return setPen(t, start.IsPenUp)
+}
+
+// setPen lifts or lowers the pen, if it isn't already, since on the pi turtle
+// every change moves the servo and waits for it.
+func setPen(t TurtleController, up bool) error {
+	if t.State().IsPenUp == up {
+		return nil
+	}
+	_, err := t.PenUp(up)
+	return err
+}
+
+// lineTo turns the turtle towards (x, y) in the Logo frame and drives there,
+// leaving it facing that way. Unlike MoveTo it doesn't turn back, which would
+// waste a turn on every stroke of a character.
+func lineTo(t TurtleController, x, y float64) error {
+	fromX, fromY := LogoPos(t.State())
+	dx, dy := x-fromX, y-fromY
+	distance := math.Hypot(dx, dy)
+	if distance < 1e-9 {
+		return nil
+	}
+	if err := reach(t, x, y); err != nil {
+		return err
+	}
+	if err := SetHeading(t, math.Atan2(dx, dy)*180/math.Pi); err != nil {
+		return err
+	}
+	_, _, err := t.Move(distance)
+	return err
+}
+
+func init() {
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		err := ctx.label(PrintValue(in.Values[0]), ctx.labelHeight)
+		if err == ErrCanceled {
+			return nil, err
+		}
+		return nil, in.turtleError(err)
+	}), "LABEL")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		height, err := in.Number(0)
+		if err != nil {
+			return nil, err
+		}
+		if height <= 0 {
+			return nil, in.doesntLike(0)
+		}
+		ctx.labelHeight = height
+		return nil, nil
+	}), "SETLABELHEIGHT")
+}
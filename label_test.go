@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+func TestFontHasPrintableASCII(t *testing.T) {
+	for r := ' '; r <= '~'; r++ {
+		if _, ok := font[r]; !ok {
+			t.Errorf("font has no glyph for %q", r)
+		}
+	}
+}
+
+func TestLabel(t *testing.T) {
+	tests := []struct {
+		src          string
+		wantX, wantY float64
+		wantHeading  float64
+		wantPenUp    bool
+	}{
+		// H is 22 units wide and i 8, so at height 21 the turtle ends 30 on.
+		{"SETLABELHEIGHT 21 LABEL \"Hi", 0, 30, 0, false},
+		{"SETLABELHEIGHT 42 RT 90 PU LABEL \"Hi", 60, 0, 90, true},
+		{"SETLABELHEIGHT 21 LABEL [a b]", 0, 54, 0, false},
+	}
+	for _, test := range tests {
+		program, err := ParseString("", test.src)
+		if err != nil {
+			t.Fatalf("Parse(%q) = %v", test.src, err)
+		}
+		turtle := &BaseTurtle{}
+		if err := NewContext(turtle, nil, ioutil.Discard, nil).RunProgram(program); err != nil {
+			t.Fatalf("Run(%q) = %v", test.src, err)
+		}
+		x, y := LogoPos(*turtle)
+		heading := LogoHeading(*turtle)
+		if math.Abs(x-test.wantX) > 1e-9 || math.Abs(y-test.wantY) > 1e-9 ||
+			math.Abs(heading-test.wantHeading) > 1e-9 || turtle.IsPenUp != test.wantPenUp {
+			t.Errorf("Run(%q) left the turtle at (%v, %v) heading %v pen up %v, want (%v, %v) heading %v pen up %v",
+				test.src, x, y, heading, turtle.IsPenUp, test.wantX, test.wantY, test.wantHeading, test.wantPenUp)
+		}
+	}
+}
+
+// penCounter counts the times the pen is told to change, and fails if it's
+// told to go where it already is.
+type penCounter struct {
+	*BaseTurtle
+	t       *testing.T
+	changes int
+}
+
+func (p *penCounter) PenUp(up bool) (bool, error) {
+	if p.IsPenUp == up {
+		p.t.Fatalf("LABEL set the pen %v when it already was", penStateMap[up])
+	}
+	p.changes++
+	return p.BaseTurtle.PenUp(up)
+}
+
+func TestLabelOnlyChangesPen(t *testing.T) {
+	program, err := ParseString("", "LABEL \"Logo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	turtle := &penCounter{BaseTurtle: &BaseTurtle{}, t: t}
+	if err := NewContext(turtle, nil, ioutil.Discard, nil).RunProgram(program); err != nil {
+		t.Fatal(err)
+	}
+	// Each stroke lifts the pen to get to it and lowers it, and at the end
+	// the pen is lifted to get to the next character and lowered again.
+	want := 2
+	for _, r := range "Logo" {
+		want += 2 * len(font[r].strokes)
+	}
+	if turtle.changes != want {
+		t.Errorf("LABEL changed the pen %d times, want %d", turtle.changes, want)
+	}
+}
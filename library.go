@@ -0,0 +1,38 @@
+package main
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed stdlib.logo
+var librarySource string
+
+// library is the procedures every session starts with, from stdlib.logo,
+// keyed by upper case name.
+var library = parseLibrary(librarySource)
+
+func parseLibrary(src string) map[string]*To {
+	program, err := ParseString("stdlib.logo", src)
+	if err != nil {
+		panic(err)
+	}
+	procs := map[string]*To{}
+	for _, line := range program.Lines {
+		if line.To == nil {
+			panic(errorf(line.Pos, "stdlib.logo should only define procedures"))
+		}
+		procs[strings.ToUpper(line.To.Name)] = line.To
+	}
+	return procs
+}
+
+// procedure finds the procedure called key, defined with TO or else from the
+// library.
+func (ctx *Context) procedure(key string) (*To, bool) {
+	if proc, ok := ctx.Procedures[key]; ok {
+		return proc, true
+	}
+	proc, ok := ctx.Library[key]
+	return proc, ok
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestLibrary(t *testing.T) {
+	runResults(t, []resultTest{
+		{"POLYGON 4 10 (LIST POS HEADING)", "[[0 0] 0]"},
+		{"CIRCLE 10 POS", "[0 0]"},
+		{"ARC 90 10 (LIST POS HEADING)", "[[10 10] 90]"},
+		{"KOCH 27 2 POS", "[0 27]"},
+		// A procedure of the same name replaces the library's, and erasing
+		// it brings the library's back.
+		{"TO POLYGON :a :b\nPRINT \"mine\nEND\nPOLYGON 4 10 ERASE \"POLYGON POLYGON 4 10 POS", "mine\n[0 0]"},
+		// The library isn't part of the workspace.
+		{"POTS", ""},
+	})
+}
+
+func TestLibraryErrorLine(t *testing.T) {
+	src := "fd 1\nfd 2\nfd 3\nfd 4\nfd 5\nfd 6\npolygon 3 \"x\n"
+	program, err := ParseString("test", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewContext(NewTextTurtle(ioutil.Discard), nil, ioutil.Discard, nil)
+	err = ctx.RunProgram(program)
+	if err == nil {
+		t.Fatal("Run(polygon 3 \"x) succeeded")
+	}
+	// The error is in stdlib.logo, so no line of test goes with it.
+	if got := ShowError(err, "test", src); got != err.Error() {
+		t.Errorf("ShowError = %q, want %q", got, err.Error())
+	}
+}
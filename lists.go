@@ -0,0 +1,161 @@
+package main
+
+import (
+	"strings"
+)
+
+// Words and lists share most of their primitives. A word is treated as a
+// list of one character words.
+
+// sequence returns input i as a list, or as the characters of a word.
+func (in *Inputs) sequence(i int) (items []interface{}, isWord bool, err error) {
+	if list, ok := in.Values[i].(List); ok {
+		return list.Items, false, nil
+	}
+	word, err := in.Word(i)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, c := range word {
+		items = append(items, string(c))
+	}
+	return items, true, nil
+}
+
+// nonEmpty is sequence for primitives that need at least one item.
+func (in *Inputs) nonEmpty(i int) ([]interface{}, bool, error) {
+	items, isWord, err := in.sequence(i)
+	if err == nil && len(items) == 0 {
+		err = in.doesntLike(i)
+	}
+	return items, isWord, err
+}
+
+// rebuild turns items back into the kind of thing they came from.
+func rebuild(items []interface{}, isWord bool) interface{} {
+	if !isWord {
+		return NewList(append([]interface{}{}, items...)...)
+	}
+	var b strings.Builder
+	for _, item := range items {
+		b.WriteString(item.(string))
+	}
+	return b.String()
+}
+
+func init() {
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		items, _, err := in.nonEmpty(0)
+		if err != nil {
+			return nil, err
+		}
+		return items[0], nil
+	}), "FIRST")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		items, isWord, err := in.nonEmpty(0)
+		if err != nil {
+			return nil, err
+		}
+		return rebuild(items[1:], isWord), nil
+	}), "BUTFIRST", "BF")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		items, _, err := in.nonEmpty(0)
+		if err != nil {
+			return nil, err
+		}
+		return items[len(items)-1], nil
+	}), "LAST")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		items, isWord, err := in.nonEmpty(0)
+		if err != nil {
+			return nil, err
+		}
+		return rebuild(items[:len(items)-1], isWord), nil
+	}), "BUTLAST", "BL")
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		if list, ok := in.Values[1].(List); ok {
+			return NewList(append([]interface{}{in.Values[0]}, list.Items...)...), nil
+		}
+		return joinWords(ctx, in)
+	}), "FPUT")
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		if list, ok := in.Values[1].(List); ok {
+			items := append([]interface{}{}, list.Items...)
+			return NewList(append(items, in.Values[0])...), nil
+		}
+		// LPUT "c "ab is WORD "ab "c, with errors still at the right input.
+		in.Values[0], in.Values[1] = in.Values[1], in.Values[0]
+		if len(in.Positions) == 2 {
+			in.Positions[0], in.Positions[1] = in.Positions[1], in.Positions[0]
+		}
+		return joinWords(ctx, in)
+	}), "LPUT")
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		index, err := in.Number(0)
+		if err != nil {
+			return nil, err
+		}
+		items, _, err := in.sequence(1)
+		if err != nil {
+			return nil, err
+		}
+		if index != float64(int(index)) || index < 1 || int(index) > len(items) {
+			return nil, in.doesntLike(0)
+		}
+		return items[int(index)-1], nil
+	}), "ITEM")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		items, _, err := in.sequence(0)
+		if err != nil {
+			return nil, err
+		}
+		return float64(len(items)), nil
+	}), "COUNT")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		items, _, err := in.sequence(0)
+		if err != nil {
+			return nil, err
+		}
+		return len(items) == 0, nil
+	}), "EMPTYP", "EMPTY?")
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		items, _, err := in.sequence(1)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if valuesEqual(in.Values[0], item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}), "MEMBERP", "MEMBER?")
+	definePrimitive(variadic(2, 0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		var items []interface{}
+		for _, value := range in.Values {
+			if list, ok := value.(List); ok {
+				items = append(items, list.Items...)
+			} else {
+				items = append(items, value)
+			}
+		}
+		return NewList(items...), nil
+	}), "SENTENCE", "SE")
+	definePrimitive(variadic(2, 0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		return NewList(append([]interface{}{}, in.Values...)...), nil
+	}), "LIST")
+	definePrimitive(variadic(2, 0, joinWords), "WORD")
+}
+
+// joinWords concatenates every input, which must all be words.
+func joinWords(ctx *Context, in *Inputs) (interface{}, error) {
+	var b strings.Builder
+	for i := range in.Values {
+		word, err := in.Word(i)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(word)
+	}
+	return b.String(), nil
+}
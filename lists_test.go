@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLists(t *testing.T) {
+	runResults(t, []resultTest{
+		{"FIRST [a b c]", "a"},
+		{"BUTFIRST [a b c]", "[b c]"},
+		{"LAST \"abc", "c"},
+		{"BL \"abc", "ab"},
+		{"FPUT \"a [b c]", "[a b c]"},
+		{"LPUT \"c [a b]", "[a b c]"},
+		{"LPUT \"c \"ab", "abc"},
+		{"ITEM 2 [a [b c] d]", "[b c]"},
+		{"COUNT [a [b c] d]", "3"},
+		{"LIST EMPTYP [] EMPTY? \"a", "[true false]"},
+		{"LIST MEMBERP 2 [1 2 3] MEMBER? \"B \"abc", "[true true]"},
+		{"SENTENCE [a b] \"c", "[a b c]"},
+		{"(SE [a] [b [c]] \"d)", "[a b [c] d]"},
+		{"LIST [a] \"b", "[[a] b]"},
+		{"(WORD \"a 1 \"b)", "a1b"},
+	})
+}
+
+func TestListErrors(t *testing.T) {
+	runErrors(t, []resultTest{
+		{"FIRST []", "1:7: FIRST doesn't like [] as input"},
+		{"ITEM 4 [a b c]", "1:6: ITEM doesn't like 4 as input"},
+		{"LPUT [c] \"ab", "1:6: LPUT doesn't like [c] as input"},
+		{"WORD \"a [b]", "1:9: WORD doesn't like [b] as input"},
+	})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strings"
+)
+
+// condition evaluates input i of a loop. A list is run each time it's
+// checked, as in WHILE [:x < 10] [...].
+func (ctx *Context) condition(in *Inputs, i int) (bool, error) {
+	list, ok := in.Values[i].(List)
+	if !ok {
+		return in.Bool(i)
+	}
+	value, err := ctx.runList(in.Pos, list, true)
+	if err != nil {
+		return false, err
+	}
+	b, ok := toBool(value)
+	if !ok {
+		return false, in.errorf("%s doesn't like %s as a condition, it needs true or false", in.Name, FormatValue(value))
+	}
+	return b, nil
+}
+
+// loop runs the body in input i for as long as more returns true, checking
+// before each pass, or after if atLeastOnce is set. Like REPEAT, REPCOUNT
+// counts the passes.
+func (ctx *Context) loop(in *Inputs, i int, atLeastOnce bool, more func() (bool, error)) (interface{}, error) {
+	saved := ctx.repcount
+	defer func() { ctx.repcount = saved }()
+	for pass := 1; ; pass++ {
+		if err := ctx.checkCanceled(); err != nil {
+			return nil, err
+		}
+		if pass > 1 || !atLeastOnce {
+			ok, err := more()
+			if err != nil || !ok {
+				return nil, err
+			}
+		}
+		ctx.repcount = pass
+		if _, err := ctx.RunValue(in, i, false); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func init() {
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		return ctx.loop(in, 1, false, func() (bool, error) {
+			return ctx.condition(in, 0)
+		})
+	}), "WHILE")
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		return ctx.loop(in, 1, false, func() (bool, error) {
+			done, err := ctx.condition(in, 0)
+			return !done, err
+		})
+	}), "UNTIL")
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		return ctx.loop(in, 0, true, func() (bool, error) {
+			return ctx.condition(in, 1)
+		})
+	}), "DO.WHILE")
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		return ctx.loop(in, 0, true, func() (bool, error) {
+			done, err := ctx.condition(in, 1)
+			return !done, err
+		})
+	}), "DO.UNTIL")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		return ctx.loop(in, 0, false, func() (bool, error) {
+			return true, nil
+		})
+	}), "FOREVER")
+	definePrimitive(fixed(2, forLoop), "FOR")
+}
+
+// forLoop runs FOR [var start end step] [instructions]. The step defaults to
+// 1, or -1 when counting down, and start, end and step may be expressions.
+func forLoop(ctx *Context, in *Inputs) (interface{}, error) {
+	control, err := in.List(0)
+	if err != nil {
+		return nil, err
+	}
+	if len(control.Items) < 3 {
+		return nil, in.errorf("%s needs [variable start end] or [variable start end step], not %s",
+			in.Name, FormatValue(control))
+	}
+	name, ok := toWord(control.Items[0])
+	if !ok {
+		return nil, in.doesntLike(0)
+	}
+	items, err := listExpressions(in.Pos, control)
+	if err != nil {
+		return nil, err
+	}
+	s := &stream{items: items, next: 1}
+	var limits []float64
+	for s.more() {
+		value, err := ctx.evalNext(s)
+		if err != nil {
+			return nil, err
+		}
+		n, ok := toNumber(value)
+		if !ok {
+			return nil, in.errorf("%s doesn't like %s in its control list", in.Name, FormatValue(value))
+		}
+		limits = append(limits, n)
+	}
+	if len(limits) != 2 && len(limits) != 3 {
+		return nil, in.errorf("%s needs [variable start end] or [variable start end step], not %s",
+			in.Name, FormatValue(control))
+	}
+	start, end := limits[0], limits[1]
+	step := 1.0
+	if end < start {
+		step = -1
+	}
+	if len(limits) == 3 {
+		step = limits[2]
+	}
+	if step == 0 {
+		return nil, in.errorf("%s can't count in steps of 0", in.Name)
+	}
+
+	frame := map[string]interface{}{}
+	key := strings.ToLower(name)
+	ctx.frames = append(ctx.frames, frame)
+	defer func() { ctx.frames = ctx.frames[:len(ctx.frames)-1] }()
+	for value := start; (step > 0 && value <= end) || (step < 0 && value >= end); value += step {
+		if err := ctx.checkCanceled(); err != nil {
+			return nil, err
+		}
+		frame[key] = value
+		if _, err := ctx.RunValue(in, 1, false); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
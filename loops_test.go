@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestLoops(t *testing.T) {
+	runResults(t, []resultTest{
+		{"MAKE \"s [] MAKE \"i 0 WHILE [:i < 3] [MAKE \"i :i + 1 MAKE \"s LPUT :i :s] :s", "[1 2 3]"},
+		{"MAKE \"i 0 UNTIL [:i = 2] [MAKE \"i :i + 1] :i", "2"},
+		{"MAKE \"i 0 DO.WHILE [MAKE \"i :i + 1] \"false :i", "1"},
+		{"MAKE \"i 5 DO.UNTIL [MAKE \"i :i + 1] [:i > 2] :i", "6"},
+		{"MAKE \"s [] FOR [i 1 3] [MAKE \"s LPUT :i :s] :s", "[1 2 3]"},
+		{"MAKE \"s [] FOR [i 3 1] [MAKE \"s LPUT :i :s] :s", "[3 2 1]"},
+		{"MAKE \"s [] FOR [i 0 1 0.5] [MAKE \"s LPUT :i :s] :s", "[0 0.5 1]"},
+		{"MAKE \"s [] FOR [i 1 0 1] [MAKE \"s LPUT :i :s] :s", "[]"},
+		{"MAKE \"i 0 WHILE [\"false] [MAKE \"i 1] :i", "0"},
+		{"TO F\nFOREVER [IF REPCOUNT = 3 [OUTPUT REPCOUNT]]\nEND\nF", "3"},
+	})
+}
+
+func TestLoopErrors(t *testing.T) {
+	runErrors(t, []resultTest{
+		{"WHILE [1] [FD 1]", "1:1: WHILE doesn't like 1 as a condition, it needs true or false"},
+		{"FOR [i 1] [FD :i]", "1:1: FOR needs [variable start end] or [variable start end step], not [i 1]"},
+	})
+}
+
+func TestForeverCanceled(t *testing.T) {
+	program, err := ParseString("", "FOREVER [FD 1]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewContext(&BaseTurtle{}, nil, ioutil.Discard, nil)
+	canceled := make(chan struct{})
+	ctx.Canceled = canceled
+	time.AfterFunc(10*time.Millisecond, func() { close(canceled) })
+	if err := ctx.RunProgram(program); err != ErrCanceled {
+		t.Errorf("Run(FOREVER) = %v, want %v", err, ErrCanceled)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxLSystem is the most symbols LSYSTEM will draw, so that a few too many
+// iterations fail straight away instead of plotting all night.
+const maxLSystem = 1 << 20
+
+// lsystemString turns an axiom, a rule or a symbol into a string of symbols.
+// A word may be quoted, and between bars, as it is when it was read inside a
+// list. A list is its words run together with its sublists in brackets, so
+// [F ["+F] F] is F[+F]F.
+func lsystemString(v interface{}) (string, bool) {
+	if list, ok := v.(List); ok {
+		var b strings.Builder
+		for _, item := range list.Items {
+			s, ok := lsystemString(item)
+			if !ok {
+				return "", false
+			}
+			if _, ok := item.(List); ok {
+				s = "[" + s + "]"
+			}
+			b.WriteString(s)
+		}
+		return b.String(), true
+	}
+	word, ok := toWord(v)
+	word = strings.TrimPrefix(word, `"`)
+	word, _ = unbar(word)
+	return word, ok
+}
+
+// symbols reads input i, a list of symbols each followed by a value, as
+// LSYSTEM's rules and actions are written. A symbol may be quoted, which
+// lets + start the list.
+func (in *Inputs) symbols(i int) (map[rune]interface{}, error) {
+	list, err := in.List(i)
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Items)%2 != 0 {
+		return nil, in.doesntLike(i)
+	}
+	symbols := map[rune]interface{}{}
+	for j := 0; j < len(list.Items); j += 2 {
+		_, isList := list.Items[j].(List)
+		word, ok := lsystemString(list.Items[j])
+		if !ok || isList || utf8.RuneCountInString(word) != 1 {
+			return nil, in.doesntLike(i)
+		}
+		r, _ := utf8.DecodeRuneInString(word)
+		symbols[r] = list.Items[j+1]
+	}
+	return symbols, nil
+}
+
+// rewrite applies rules to every symbol of s at once, n times over.
+func rewrite(s string, rules map[rune]string, n int) (string, bool) {
+	for i := 0; i < n; i++ {
+		var b strings.Builder
+		for _, r := range s {
+			if replacement, ok := rules[r]; ok {
+				b.WriteString(replacement)
+			} else {
+				b.WriteRune(r)
+			}
+			if b.Len() > maxLSystem {
+				return "", false
+			}
+		}
+		s = b.String()
+	}
+	return s, true
+}
+
+func init() {
+	// LSYSTEM axiom rules iterations actions rewrites the axiom with the
+	// rules, as in LSYSTEM "F [F [F ["+F] F [-F] F]] 4 [F [FD 5] + [LT 25] - [RT 25]],
+	// and then runs each symbol's action in turn. [ and ] save and go back to
+	// the turtle, like PUSHTURTLE and POPTURTLE; other symbols without an
+	// action are skipped.
+	definePrimitive(fixed(4, func(ctx *Context, in *Inputs) (interface{}, error) {
+		axiom, ok := lsystemString(in.Values[0])
+		if !ok {
+			return nil, in.doesntLike(0)
+		}
+		symbols, err := in.symbols(1)
+		if err != nil {
+			return nil, err
+		}
+		rules := map[rune]string{}
+		for r, replacement := range symbols {
+			if rules[r], ok = lsystemString(replacement); !ok {
+				return nil, in.doesntLike(1)
+			}
+		}
+		n, err := in.Number(2)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 || n != float64(int(n)) {
+			return nil, in.doesntLike(2)
+		}
+		symbols, err = in.symbols(3)
+		if err != nil {
+			return nil, err
+		}
+		actions := map[rune][]*Expression{}
+		for r, action := range symbols {
+			list, ok := action.(List)
+			if !ok {
+				return nil, in.doesntLike(3)
+			}
+			if actions[r], err = listExpressions(in.Pos, list); err != nil {
+				return nil, err
+			}
+		}
+
+		s, ok := rewrite(axiom, rules, int(n))
+		if !ok {
+			return nil, in.errorf("%s would draw more than %d symbols", in.Name, maxLSystem)
+		}
+		base := len(ctx.turtles)
+		defer func() { ctx.turtles = ctx.turtles[:base] }()
+		for _, r := range s {
+			switch r {
+			case '[':
+				ctx.pushTurtle()
+			case ']':
+				if len(ctx.turtles) == base {
+					return nil, in.errorf("%s has a ] without a [", in.Name)
+				}
+				if err := ctx.popTurtle(); err != nil {
+					return nil, in.turtleError(err)
+				}
+			default:
+				if action, ok := actions[r]; ok {
+					if _, err := ctx.RunList(action, false); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		return nil, nil
+	}), "LSYSTEM")
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	rules := map[rune]string{'A': "AB", 'B': "A"}
+	if got, _ := rewrite("A", rules, 4); got != "ABAABABA" {
+		t.Errorf("rewrite(A, 4) = %q, want ABAABABA", got)
+	}
+	if _, ok := rewrite("A", map[rune]string{'A': "AA"}, 30); ok {
+		t.Errorf("rewrite(A, 30) doubling each time should be too big")
+	}
+}
+
+func TestLSystemString(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want string
+	}{
+		{"F", "F"},
+		{`"+`, "+"},
+		{`"|F[+F]F|`, "F[+F]F"},
+		{List{Items: []interface{}{"F", List{Items: []interface{}{"+", "F"}}, "F"}}, "F[+F]F"},
+		{List{Items: []interface{}{`"|F[-F]|`, "F"}}, "F[-F]F"},
+	}
+	for _, test := range tests {
+		if got, ok := lsystemString(test.v); !ok || got != test.want {
+			t.Errorf("lsystemString(%v) = %q, %v, want %q", test.v, got, ok, test.want)
+		}
+	}
+}
+
+func TestLSystem(t *testing.T) {
+	tests := []struct {
+		src          string
+		wantX, wantY float64
+		wantHeading  float64
+	}{
+		// The branches go back to where they started, leaving the trunk.
+		{`LSYSTEM "F [F [F ["+F] F [-F] F]] 2 [F [FD 1] + [LT 25] - [RT 25]]`, 0, 9, 0},
+		{`LSYSTEM "F [F "|F[+F]F[-F]F|] 2 [F [FD 1] + [LT 25] - [RT 25]]`, 0, 9, 0},
+		{`LSYSTEM "F+F+F+F [F "F+F-F-FF+F+F-F] 2 ["+ [RT 90] F [FD 1] - [LT 90]]`, 0, 0, 270},
+		{`PU FD 5 PUSHTURTLE PD RT 45 FD 10 POPTURTLE`, 0, 5, 0},
+	}
+	for _, test := range tests {
+		program, err := ParseString("", test.src)
+		if err != nil {
+			t.Fatalf("Parse(%q) = %v", test.src, err)
+		}
+		turtle := &BaseTurtle{}
+		if err := NewContext(turtle, nil, ioutil.Discard, nil).RunProgram(program); err != nil {
+			t.Fatalf("Run(%q) = %v", test.src, err)
+		}
+		x, y := LogoPos(*turtle)
+		heading := LogoHeading(*turtle)
+		if math.Abs(x-test.wantX) > 1e-9 || math.Abs(y-test.wantY) > 1e-9 || math.Abs(heading-test.wantHeading) > 1e-9 {
+			t.Errorf("Run(%q) left the turtle at (%v, %v) heading %v, want (%v, %v) heading %v",
+				test.src, x, y, heading, test.wantX, test.wantY, test.wantHeading)
+		}
+	}
+}
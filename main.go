@@ -1,92 +1,409 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
 	"time"
-
-	"github.com/chzyer/readline"
 )
 
-func NewWheel(pins []int) (*GPIOStepper, error) {
-	gpioPins, err := InitGPIOPins(pins)
-	if err != nil {
-		return nil, err
-	}
+func NewWheel(pins []GPIO) (*GPIOStepper, error) {
 	wheel, err := NewGPIOStepper(
 		time.Millisecond,
-		gpioPins,
+		pins,
 		StandardStepperPattern,
 	)
 	return wheel, err
 }
 
 func main() {
-	var usePiTurtle bool
-	var fileName string
+	var usePiTurtle, useSimTurtle, plan, optimize bool
+	var fileName, gpioTrace, record, out string
+	var seed int64
+	var paper string
+	var margin, stepMM float64
+	var searchPath pathList
 	flag.BoolVar(&usePiTurtle, "pi", false, "Use the pi turtle")
+	flag.BoolVar(&useSimTurtle, "sim", false, "Use the pi turtle on simulated pins")
+	flag.StringVar(&out, "out", "", "Turtles to draw with at once, like pi,svg=preview.svg: text, pi, sim or svg=file.svg, the first in charge, with ! after any other whose errors should stop the program")
 	flag.StringVar(&fileName, "file", "", "Run this program")
+	flag.BoolVar(&plan, "plan", false, "Run the whole program and check it before drawing any of it")
+	flag.BoolVar(&optimize, "optimize", false, "Plan, then draw the lines in whatever order and direction keeps pen-up travel short")
+	flag.StringVar(&gpioTrace, "gpiotrace", "", "Record pi turtle pin transitions to this file")
+	flag.StringVar(&record, "record", "", "Record every turtle move, turn and pen change to this file")
+	flag.Var(&searchPath, "I", "Directory for LOAD to look in, may be repeated")
+	flag.StringVar(&paper, "paper", "a4", "Size of the page for FENCE and WRAP: a3, a4, a5, letter, legal or a size in mm like 297x210")
+	flag.Float64Var(&margin, "margin", 10, "Space in mm to leave around the edge of the page")
+	flag.Float64Var(&stepMM, "stepmm", 1, "How many mm the turtle goes in one step, to size -paper and -margin in steps; 1 until the turtle is calibrated")
+	flag.Int64Var(&seed, "seed", 0, "Seed for RANDOM, PICK and SHUFFLE, to draw the same picture again")
 	flag.Parse()
+
+	if flag.NArg() > 0 {
+		switch flag.Arg(0) {
+		case "odometry":
+			runOdometry(flag.Args()[1:])
+		case "check":
+			runCheck(flag.Args()[1:], searchPath)
+		case "fmt":
+			runFmt(flag.Args()[1:])
+		case "replay":
+			// Needs the turtle, so it's run below.
+		default:
+			log.Fatalf("Unknown command %q", flag.Arg(0))
+		}
+		if flag.Arg(0) != "replay" {
+			return
+		}
+	}
+
 	log.Print("Welcome to jlogo!")
-	var turtle Turtle
-	if usePiTurtle {
-		log.Print("Using pi turtle!")
-		turtle = InitPiTurtle()
-		defer turtle.Close()
-	} else {
-		log.Print("Using text turtle!")
-		turtle = NewTextTurtle(os.Stdout)
+	width, height, err := ParsePaper(paper)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if width <= 2*margin || height <= 2*margin {
+		log.Fatalf("A margin of %vmm leaves no room on %s paper", margin, paper)
+	}
+	if stepMM <= 0 {
+		log.Fatalf("A step of %vmm doesn't go anywhere", stepMM)
 	}
 
+	// Once the outputs are open, a failure sets the exit status instead of
+	// calling log.Fatal, so that the deferred Closes still finish the SVG,
+	// write the traces and lift the pi turtle's pen. This runs after them.
+	status := 0
+	defer func() {
+		if status != 0 {
+			os.Exit(status)
+		}
+	}()
+	var trace *GPIOTrace
+	var recorder *SimRecorder
+	if gpioTrace != "" {
+		f, err := os.Create(gpioTrace)
+		if err != nil {
+			log.Fatalf("Error creating trace %s, got %v", gpioTrace, err)
+		}
+		defer f.Close()
+		trace = NewGPIOTrace(f)
+		defer func() {
+			if recorder != nil {
+				err = recorder.WriteTrace(f)
+			} else {
+				err = trace.Err()
+			}
+			if err != nil {
+				log.Printf("Error writing trace %s, got %v", gpioTrace, err)
+			}
+		}()
+	}
+	if out == "" {
+		switch {
+		case usePiTurtle:
+			out = "pi"
+		case useSimTurtle:
+			out = "sim"
+		default:
+			out = "text"
+		}
+	}
+	turtle, recorder, err := openOutputs(out, trace)
+	if err != nil {
+		log.Print(err)
+		status = 1
+		return
+	}
+	defer turtle.Close()
+	if record != "" {
+		f, err := os.Create(record)
+		if err != nil {
+			log.Printf("Error creating recording %s, got %v", record, err)
+			status = 1
+			return
+		}
+		defer f.Close()
+		recording := NewRecordingTurtle(turtle, f)
+		turtle = recording
+		defer func() {
+			if err := recording.Err(); err != nil {
+				log.Printf("Error writing recording %s, got %v", record, err)
+			}
+		}()
+	}
+
+	if flag.Arg(0) == "replay" {
+		if err := runReplay(flag.Args()[1:], turtle); err != nil {
+			log.Print(err)
+			status = 1
+		}
+		return
+	}
+
+	turtle = NewBoundedTurtle(turtle, (width-2*margin)/stepMM, (height-2*margin)/stepMM)
+
+	// Any seed can be given, 0 too, so only one that wasn't comes from the time.
+	seedSet := false
+	flag.Visit(func(f *flag.Flag) { seedSet = seedSet || f.Name == "seed" })
+	if !seedSet {
+		seed = time.Now().UnixNano()
+	}
+	log.Printf("Using random seed %d", seed)
+	session := NewSession(turtle, os.Stdin, os.Stdout, map[string]Function{})
+	session.Random = NewRandom(seed)
+	session.SearchPath = searchPath
+	session.Plan = plan
+	session.Optimize = optimize
+
 	if fileName != "" {
-		runProgramFromFile(fileName, turtle)
+		err = runProgramFromFile(fileName, session)
 	} else {
-		runProgramFromStdin(turtle)
+		err = runProgramFromStdin(session)
+	}
+	if err != nil {
+		log.Print(err)
+		status = 1
+	}
+}
+
+// openOutputs makes the turtles -out lists, driven together by a MultiTurtle
+// if there's more than one. The recorder is set if one is the simulated pi
+// turtle. If one can't be opened, the ones before it are closed again.
+func openOutputs(spec string, trace *GPIOTrace) (turtle Turtle, recorder *SimRecorder, err error) {
+	var outputs []*Output
+	defer func() {
+		if err != nil {
+			for _, output := range outputs {
+				output.Turtle.Close()
+			}
+		}
+	}()
+	pins := false
+	for _, item := range strings.Split(spec, ",") {
+		kind, arg := item, ""
+		if i := strings.Index(item, "="); i >= 0 {
+			kind, arg = item[:i], item[i+1:]
+		}
+		fatal := strings.HasSuffix(kind, "!")
+		kind = strings.TrimSuffix(kind, "!")
+		if (kind == "pi" || kind == "sim") && pins {
+			return nil, nil, errors.New("Only one of pi and sim can be used at once")
+		}
+		var turtle Turtle
+		switch kind {
+		case "text":
+			log.Print("Using text turtle!")
+			turtle = NewTextTurtle(os.Stderr)
+		case "pi":
+			log.Print("Using pi turtle!")
+			turtle = InitPiTurtle(trace)
+			pins = true
+		case "sim":
+			log.Print("Using simulated pi turtle!")
+			recorder = NewSimRecorder()
+			simTurtle, err := NewSimPiTurtle(os.Stderr, recorder)
+			if err != nil {
+				return nil, nil, err
+			}
+			turtle = simTurtle
+			pins = true
+		case "svg":
+			if arg == "" {
+				return nil, nil, errors.New("Say where to write the SVG, as in svg=preview.svg")
+			}
+			f, err := os.Create(arg)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Error creating %s, got %v", arg, err)
+			}
+			log.Printf("Drawing a preview in %s", arg)
+			turtle = NewSVGTurtle(f)
+		default:
+			return nil, nil, fmt.Errorf("Unknown output %q, want text, pi, sim or svg=file.svg", kind)
+		}
+		outputs = append(outputs, &Output{Turtle: turtle, Name: kind, Fatal: fatal})
+	}
+	if len(outputs) == 1 {
+		return outputs[0].Turtle, recorder, nil
+	}
+	return NewMultiTurtle(os.Stderr, outputs...), recorder, nil
+}
+
+// pathList is a flag that can be given more than once.
+type pathList []string
+
+func (p *pathList) String() string {
+	return strings.Join(*p, string(filepath.ListSeparator))
+}
+
+func (p *pathList) Set(dir string) error {
+	*p = append(*p, dir)
+	return nil
+}
+
+// runOdometry renders the path recorded in a GPIO trace as SVG.
+func runOdometry(args []string) {
+	fs := flag.NewFlagSet("odometry", flag.ExitOnError)
+	var outName string
+	var settle time.Duration
+	fs.StringVar(&outName, "o", "", "Write the SVG here instead of stdout")
+	fs.DurationVar(&settle, "settle", 500*time.Microsecond, "Pin transitions closer than this are one step")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: jlogo odometry [-o out.svg] trace.jsonl")
+	}
+
+	r, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Error reading trace %s, got %v", fs.Arg(0), err)
+	}
+	defer r.Close()
+	events, err := ReadTrace(r)
+	if err != nil {
+		log.Fatalf("Error reading trace %s, got %v", fs.Arg(0), err)
+	}
+	o := ReconstructPath(events, settle)
+	log.Printf("Replayed %v: left wheel %d steps, right wheel %d steps", o.Duration, o.LeftSteps, o.RightSteps)
+	log.Printf("Ended at (%v, %v) facing %v", o.Pose.X, o.Pose.Y, o.Pose.Heading)
+	if o.Skipped > 0 || o.Unknown > 0 {
+		log.Printf("Found %d skipped phases and %d unknown pin states", o.Skipped, o.Unknown)
+	}
+
+	w := os.Stdout
+	if outName != "" {
+		w, err = os.Create(outName)
+		if err != nil {
+			log.Fatalf("Error creating %s, got %v", outName, err)
+		}
+		defer w.Close()
+	}
+	if err := WriteSVG(w, o.Strokes); err != nil {
+		log.Fatalf("Error writing SVG, got %v", err)
 	}
 }
 
-func runProgramFromStdin(turtle Turtle) {
-	rl, err := readline.New("> ")
+// runReplay makes the calls in a recording on turtle, the one the flags
+// before "replay" choose.
+func runReplay(args []string, turtle Turtle) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Usage: jlogo [-pi|-sim] replay recording.jsonl")
+	}
+	r, err := os.Open(args[0])
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("Error reading recording %s, got %v", args[0], err)
 	}
-	defer rl.Close()
-	program := &Program{}
+	defer r.Close()
+	if err := Replay(r, turtle); err != nil {
+		return fmt.Errorf("Error replaying %s, got %v", args[0], err)
+	}
+	return nil
+}
 
-	for {
-		line, err := rl.Readline()
-		if err != nil { // io.EOF
-			break
+// runCheck reports the mistakes Check finds in each file, looking for the
+// files they LOAD in searchPath too, and exits with status 1 if there are any.
+func runCheck(files []string, searchPath []string) {
+	if len(files) == 0 {
+		log.Fatal("Usage: jlogo check file.logo...")
+	}
+	failed := false
+	for _, fileName := range files {
+		src, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			log.Fatalf("Error reading file %s, got %v", fileName, err)
 		}
-		line += "\n"
-		err = basicParser.ParseString("", line, program) //program, err := Parse(strings.NewReader(line))
+		program, err := ParseString(fileName, string(src))
 		if err != nil {
-			log.Printf("Error parsing line [%v], got %v", line, err)
+			fmt.Fprintln(os.Stderr, ShowError(err, fileName, string(src)))
+			failed = true
+			continue
+		}
+		for _, problem := range Check(program, searchPath) {
+			fmt.Fprintln(os.Stderr, ShowError(problem, fileName, string(src)))
+			failed = true
 		}
-		funcs := map[string]Function{}
-		err = program.Evaluate(turtle, os.Stdin, os.Stdout, funcs)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// runFmt prints each file in canonical form, or rewrites it with -w.
+func runFmt(args []string) {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	var write, expand bool
+	fs.BoolVar(&write, "w", false, "Write the result back to the file instead of stdout")
+	fs.BoolVar(&expand, "expand", false, "Write abbreviations like FD in full")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("Error reading stdin, got %v", err)
+		}
+		program, err := ParseString("stdin", string(src))
 		if err != nil {
-			log.Printf("Error running program, got %v", err)
+			log.Fatalf("Error parsing program, got %s", ShowError(err, "stdin", string(src)))
+		}
+		fmt.Print(Format(program, expand))
+		return
+	}
+	for _, fileName := range fs.Args() {
+		src, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			log.Fatalf("Error reading file %s, got %v", fileName, err)
+		}
+		program, err := ParseString(fileName, string(src))
+		if err != nil {
+			log.Fatalf("Error parsing program, got %s", ShowError(err, fileName, string(src)))
+		}
+		formatted := Format(program, expand)
+		if !write {
+			fmt.Print(formatted)
+			continue
+		}
+		if err := ioutil.WriteFile(fileName, []byte(formatted), 0644); err != nil {
+			log.Fatalf("Error writing file %s, got %v", fileName, err)
 		}
 	}
 }
 
-func runProgramFromFile(fileName string, turtle Turtle) {
-	r, err := os.Open(fileName)
+func runProgramFromFile(fileName string, session *Session) error {
+	src, err := ioutil.ReadFile(fileName)
 	if err != nil {
-		log.Fatalf("Error reading file %s, got %v", fileName, err)
+		return fmt.Errorf("Error reading file %s, got %v", fileName, err)
 	}
-	defer r.Close()
-	program, err := Parse(r)
+	program, err := ParseString(fileName, string(src))
 	if err != nil {
-		log.Fatalf("Error parsing program, got %v", err)
+		return fmt.Errorf("Error parsing program, got %s", ShowError(err, fileName, string(src)))
 	}
 	log.Printf("%+v", program)
 
-	funcs := map[string]Function{}
-	err = program.Evaluate(turtle, os.Stdin, os.Stdout, funcs)
+	err = runInterruptible(session, program)
 	if err != nil {
-		log.Fatalf("Error running program, got %v", err)
+		return fmt.Errorf("Error running program, got %s", ShowError(err, fileName, string(src)))
 	}
+	return nil
+}
+
+// runInterruptible runs program, canceling it on Ctrl-C so that FOREVER and
+// friends can be stopped without killing jlogo.
+func runInterruptible(session *Session, program *Program) error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	canceled := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-interrupt:
+			close(canceled)
+		case <-done:
+		}
+	}()
+
+	return session.Run(program, canceled)
 }
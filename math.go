@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math"
+)
+
+// number checks the result of a math primitive, which Logo has no way to
+// represent if it isn't finite.
+func (in *Inputs) number(n float64) (interface{}, error) {
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return nil, in.errorf("%s can't work out %s", in.Name, FormatValue(NewList(in.Values...)))
+	}
+	return n, nil
+}
+
+// unary is a primitive that applies fn to a single number.
+func unary(fn func(float64) float64) *Primitive {
+	return fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		n, err := in.Number(0)
+		if err != nil {
+			return nil, err
+		}
+		return in.number(fn(n))
+	})
+}
+
+// binary is a primitive that applies fn to two numbers.
+func binary(fn func(a, b float64) float64) *Primitive {
+	return fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		numbers, err := in.Numbers()
+		if err != nil {
+			return nil, err
+		}
+		return in.number(fn(numbers[0], numbers[1]))
+	})
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+func init() {
+	// Logic.
+	definePrimitive(variadic(2, 0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		for i := range in.Values {
+			b, err := in.Bool(i)
+			if err != nil || !b {
+				return false, err
+			}
+		}
+		return true, nil
+	}), "AND")
+	definePrimitive(variadic(2, 0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		for i := range in.Values {
+			b, err := in.Bool(i)
+			if err != nil || b {
+				return b, err
+			}
+		}
+		return false, nil
+	}), "OR")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		b, err := in.Bool(0)
+		return !b, err
+	}), "NOT")
+
+	// Arithmetic. REMAINDER takes the sign of the dividend, MODULO the sign of
+	// the divisor.
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		numbers, err := in.Numbers()
+		if err != nil {
+			return nil, err
+		}
+		if numbers[1] == 0 {
+			return nil, in.errorf("%s can't divide by zero", in.Name)
+		}
+		return math.Mod(numbers[0], numbers[1]), nil
+	}), "REMAINDER")
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		numbers, err := in.Numbers()
+		if err != nil {
+			return nil, err
+		}
+		if numbers[1] == 0 {
+			return nil, in.errorf("%s can't divide by zero", in.Name)
+		}
+		m := math.Mod(numbers[0], numbers[1])
+		if m != 0 && (m < 0) != (numbers[1] < 0) {
+			m += numbers[1]
+		}
+		return m, nil
+	}), "MODULO")
+	definePrimitive(unary(math.Trunc), "INT")
+	definePrimitive(unary(math.Round), "ROUND")
+	definePrimitive(unary(math.Abs), "ABS")
+	definePrimitive(unary(math.Sqrt), "SQRT")
+	definePrimitive(binary(math.Pow), "POWER")
+	definePrimitive(unary(math.Exp), "EXP")
+	definePrimitive(unary(func(n float64) float64 {
+		if n <= 0 {
+			return math.NaN()
+		}
+		return math.Log(n)
+	}), "LOG", "LN")
+	definePrimitive(unary(func(n float64) float64 {
+		if n <= 0 {
+			return math.NaN()
+		}
+		return math.Log10(n)
+	}), "LOG10")
+
+	// Trigonometry, in degrees like the turtle.
+	definePrimitive(unary(func(deg float64) float64 {
+		return math.Sin(radians(deg))
+	}), "SIN")
+	definePrimitive(unary(func(deg float64) float64 {
+		return math.Cos(radians(deg))
+	}), "COS")
+	definePrimitive(unary(func(deg float64) float64 {
+		if math.Mod(deg-90, 180) == 0 {
+			return math.Inf(1)
+		}
+		return math.Tan(radians(deg))
+	}), "TAN")
+	definePrimitive(&Primitive{Inputs: 1, MinInputs: 1, MaxInputs: 2, Fn: func(ctx *Context, in *Inputs) (interface{}, error) {
+		numbers, err := in.Numbers()
+		if err != nil {
+			return nil, err
+		}
+		if len(numbers) == 2 {
+			return degrees(math.Atan2(numbers[1], numbers[0])), nil
+		}
+		return degrees(math.Atan(numbers[0])), nil
+	}}, "ARCTAN")
+}
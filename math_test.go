@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestMath(t *testing.T) {
+	runResults(t, []resultTest{
+		{"AND \"true 1 = 1", "true"},
+		{"(AND \"true \"true \"false)", "false"},
+		{"OR \"false \"TRUE", "true"},
+		{"NOT 1 > 2", "true"},
+		{"LIST REMAINDER -7 3 MODULO -7 3", "[-1 2]"},
+		{"LIST REMAINDER 7 -3 MODULO 7 -3", "[1 -2]"},
+		{"(LIST INT -3.7 ROUND 2.5 ABS -4)", "[-3 3 4]"},
+		{"(LIST SQRT 16 POWER 2 10 9 ^ 0.5)", "[4 1024 3]"},
+		{"(LIST EXP 0 LN EXP 2 LOG10 1000)", "[1 2 3]"},
+		{"(LIST SIN 90 COS 180 TAN 45)", "[1 -1 1]"},
+		{"LIST ARCTAN 1 (ARCTAN -1 0)", "[45 180]"},
+		{"(LIST (SUM 1 2 3) DIFFERENCE 1 3 (PRODUCT 2 3 4) MINUS 5)", "[6 -2 24 -5]"},
+		{"\"10 + 5", "15"},
+	})
+}
+
+func TestMathErrors(t *testing.T) {
+	runErrors(t, []resultTest{
+		{"SQRT -1", "1:1: SQRT can't work out [-1]"},
+		{"LN 0", "1:1: LN can't work out [0]"},
+		{"TAN 90", "1:1: TAN can't work out [90]"},
+		{"REMAINDER 1 0", "1:1: REMAINDER can't divide by zero"},
+		{"AND 1 \"true", "1:5: AND doesn't like 1 as input, it needs true or false"},
+		{"SUM \"a 1", "1:5: SUM doesn't like a as input"},
+	})
+}
+
+func TestFunctions(t *testing.T) {
+	functions := map[string]Function{
+		"double": func(args ...interface{}) (interface{}, error) {
+			n, _ := toNumber(args[0])
+			return n * 2, nil
+		},
+		"fail": func(args ...interface{}) (interface{}, error) {
+			return nil, errors.New("no")
+		},
+	}
+	ctx := NewContext(NewTextTurtle(ioutil.Discard), nil, ioutil.Discard, functions)
+	program, err := ParseString("", "MAKE \"x (DOUBLE 21)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.RunProgram(program); err != nil || ctx.Vars["x"] != 42.0 {
+		t.Errorf("Run((DOUBLE 21)) = %v making %v, want 42", err, ctx.Vars["x"])
+	}
+	program, err = ParseString("", "MAKE \"x (FAIL)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.RunProgram(program); err == nil || err.Error() != "1:10: FAIL failed: no" {
+		t.Errorf("Run((FAIL)) = %v, want 1:10: FAIL failed: no", err)
+	}
+}
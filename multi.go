@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Output is one of the turtles a MultiTurtle drives.
+type Output struct {
+	Turtle
+	Name string
+	// Fatal outputs fail the call when they fail. Any other output that
+	// fails is dropped with a warning and the rest carry on without it.
+	Fatal bool
+	// Whether it has failed and been dropped.
+	dropped bool
+}
+
+// MultiTurtle makes each call on several turtles at once, as when the pi
+// turtle draws while an SVG preview builds alongside. The first output is in
+// charge: State is its state, a call fails when it fails, and the others are
+// only taken as far as it got.
+type MultiTurtle struct {
+	Outputs []*Output
+	// Log is told about outputs that are dropped.
+	Log io.Writer
+}
+
+func NewMultiTurtle(log io.Writer, outputs ...*Output) *MultiTurtle {
+	return &MultiTurtle{Outputs: outputs, Log: log}
+}
+
+// do makes a call on the first output with call, and then on the others with
+// c as that left it.
+func (m *MultiTurtle) do(c TurtleCall, call func(t Turtle) error) error {
+	err := call(m.Outputs[0])
+	c.State = m.Outputs[0].State()
+	if err != nil {
+		c.Error = err.Error()
+	}
+	for _, o := range m.Outputs[1:] {
+		if o.dropped {
+			continue
+		}
+		followErr := replayCall(o, c)
+		switch {
+		case followErr == nil:
+		case o.Fatal:
+			if err == nil {
+				err = fmt.Errorf("%s: %v", o.Name, followErr)
+			}
+		default:
+			o.dropped = true
+			fmt.Fprintf(m.Log, "%s failed, carrying on without it: %v\n", o.Name, followErr)
+		}
+	}
+	return err
+}
+
+func (m *MultiTurtle) Move(steps float64) (x, y float64, err error) {
+	err = m.do(TurtleCall{Call: "move", Steps: &steps}, func(t Turtle) error {
+		_, _, err := t.Move(steps)
+		return err
+	})
+	s := m.State()
+	return s.X, s.Y, err
+}
+
+func (m *MultiTurtle) Rotate(deg float64) (heading float64, err error) {
+	err = m.do(TurtleCall{Call: "rotate", Degrees: &deg}, func(t Turtle) error {
+		_, err := t.Rotate(deg)
+		return err
+	})
+	return m.State().Heading, err
+}
+
+func (m *MultiTurtle) PenUp(state bool) (bool, error) {
+	err := m.do(TurtleCall{Call: "penup", Up: &state}, func(t Turtle) error {
+		_, err := t.PenUp(state)
+		return err
+	})
+	return m.State().IsPenUp, err
+}
+
+func (m *MultiTurtle) State() BaseTurtle {
+	return m.Outputs[0].State()
+}
+
+func (m *MultiTurtle) Close() {
+	for _, o := range m.Outputs {
+		o.Close()
+	}
+}
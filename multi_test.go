@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+// brokenTurtle fails every move without going anywhere.
+type brokenTurtle struct {
+	BaseTurtle
+}
+
+func (t *brokenTurtle) Move(steps float64) (x, y float64, err error) {
+	return t.X, t.Y, errors.New("no paper")
+}
+
+func TestMultiTurtleFollowsTheFirst(t *testing.T) {
+	follower := &BaseTurtle{}
+	m := NewMultiTurtle(&bytes.Buffer{}, &Output{Turtle: &stallingTurtle{}, Name: "pi"}, &Output{Turtle: follower, Name: "svg"})
+	m.Rotate(90)
+	if _, _, err := m.Move(40); err == nil {
+		t.Errorf("Move(40) = nil, want the first output's error")
+	}
+	if want := m.State(); math.Abs(follower.X-want.X) > 1e-9 || math.Abs(follower.Y-want.Y) > 1e-9 || follower.Heading != want.Heading {
+		t.Errorf("follower is at %+v, want %+v", *follower, want)
+	}
+}
+
+func TestMultiTurtleErrors(t *testing.T) {
+	var log bytes.Buffer
+	m := NewMultiTurtle(&log, &Output{Turtle: &BaseTurtle{}, Name: "text"}, &Output{Turtle: &brokenTurtle{}, Name: "svg"})
+	if _, _, err := m.Move(10); err != nil {
+		t.Errorf("Move(10) = %v, want nil when an output that isn't fatal fails", err)
+	}
+	if !strings.Contains(log.String(), "svg failed, carrying on without it: no paper") {
+		t.Errorf("log = %q, want it to say svg was dropped", log.String())
+	}
+
+	m = NewMultiTurtle(&log, &Output{Turtle: &BaseTurtle{}, Name: "text"}, &Output{Turtle: &brokenTurtle{}, Name: "svg", Fatal: true})
+	if _, _, err := m.Move(10); err == nil || err.Error() != "svg: no paper" {
+		t.Errorf("Move(10) = %v, want svg: no paper", err)
+	}
+}
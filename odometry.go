@@ -0,0 +1,182 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+type Point struct {
+	X, Y float64
+}
+
+// Stroke is a run of connected points drawn with the pen in a single state.
+type Stroke struct {
+	PenUp  bool
+	Points []Point
+}
+
+// Odometry is the path a turtle physically drove, reconstructed from the
+// stepper phases in a GPIO trace.
+type Odometry struct {
+	// Pose at the end of the trace.
+	Pose    BaseTurtle
+	Strokes []Stroke
+	// Net steps taken by each wheel.
+	LeftSteps, RightSteps int
+	// Skipped counts phase changes of more than one step, which mean the
+	// trace missed a step or the driver was told to jump.
+	Skipped int
+	// Unknown counts settled pin states that match no stepper phase.
+	Unknown  int
+	Duration time.Duration
+	turned   bool
+}
+
+// wheelDecoder turns the pin states of one wheel back into stepper phases.
+type wheelDecoder struct {
+	index map[int]int
+	state []bool
+	// The stepper driver starts counting from phase 0 while the pins are
+	// still all off, so that's where decoding starts too.
+	phase int
+	seen  bool
+	dirty bool
+}
+
+func newWheelDecoder(pins []int) *wheelDecoder {
+	d := &wheelDecoder{
+		index: map[int]int{},
+		state: make([]bool, len(pins)),
+	}
+	for i, pin := range pins {
+		d.index[pin] = i
+	}
+	return d
+}
+
+func (d *wheelDecoder) set(pin int, value bool) bool {
+	i, ok := d.index[pin]
+	if ok {
+		d.state[i] = value
+		d.dirty = true
+	}
+	return ok
+}
+
+// settle decodes the current pin state and returns the number of steps taken
+// since the last settled state.
+func (d *wheelDecoder) settle(o *Odometry) int {
+	if !d.dirty {
+		return 0
+	}
+	d.dirty = false
+	phase := -1
+	for p, pattern := range StandardStepperPattern {
+		if boolsEqual(pattern, d.state) {
+			phase = p
+			break
+		}
+	}
+	if phase < 0 {
+		o.Unknown++
+		return 0
+	}
+	n := len(StandardStepperPattern)
+	steps := ((phase-d.phase)%n + n) % n
+	if steps > n/2 {
+		steps -= n
+	}
+	// The first step can't be checked, stepping onto the all off phase
+	// leaves no transition behind.
+	if d.seen && (steps > 1 || steps < -1) {
+		o.Skipped++
+	}
+	d.seen = true
+	d.phase = phase
+	return steps
+}
+
+func boolsEqual(a, b []bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ReconstructPath replays a trace. Pin transitions less than settle apart are
+// treated as a single step of both wheels.
+func ReconstructPath(events []TraceEvent, settle time.Duration) *Odometry {
+	o := &Odometry{}
+	o.Pose.IsPenUp = true
+	o.Strokes = []Stroke{{PenUp: true, Points: []Point{{}}}}
+	left := newWheelDecoder(PinsLeftWheel)
+	right := newWheelDecoder(PinsRightWheel)
+	penThreshold := (ServoMinDutyCycle + ServoMaxDutyCycle) / 2
+
+	for i := 0; i < len(events); {
+		j := i + 1
+		for j < len(events) && events[j].At-events[j-1].At <= settle {
+			j++
+		}
+		for _, e := range events[i:j] {
+			switch {
+			case e.DutyCycle != nil && e.Pin == PinPenServo:
+				o.penUp(*e.DutyCycle < penThreshold)
+			case e.Value != nil:
+				if !left.set(e.Pin, *e.Value) {
+					right.set(e.Pin, *e.Value)
+				}
+			}
+		}
+		o.advance(left.settle(o), right.settle(o))
+		o.Duration = events[j-1].At
+		i = j
+	}
+	return o
+}
+
+func (o *Odometry) penUp(up bool) {
+	if up == o.Pose.IsPenUp {
+		return
+	}
+	o.Pose.IsPenUp = up
+	o.Strokes = append(o.Strokes, Stroke{
+		PenUp:  up,
+		Points: []Point{{o.Pose.X, o.Pose.Y}},
+	})
+}
+
+// advance moves the pose by one step of each wheel, mirroring how PiTurtle
+// drives them: both forward to move, left forward and right back to turn.
+func (o *Odometry) advance(left, right int) {
+	if left == 0 && right == 0 {
+		return
+	}
+	o.LeftSteps += left
+	o.RightSteps += right
+	forward := float64(left+right) / 2 / StepsPerUnit
+	rotate := float64(left-right) / 2 / StepsPerDegree
+	heading := o.Pose.Heading + rotate/2
+	o.Pose.X += forward * math.Cos(deg2rad(heading))
+	o.Pose.Y += forward * math.Sin(deg2rad(heading))
+	o.Pose.Heading = math.Mod(o.Pose.Heading+rotate, 360)
+	if rotate != 0 {
+		o.turned = true
+	}
+	if forward == 0 {
+		return
+	}
+	// Straight runs only need their end point.
+	stroke := &o.Strokes[len(o.Strokes)-1]
+	if n := len(stroke.Points); n >= 2 && !o.turned {
+		stroke.Points[n-1] = Point{o.Pose.X, o.Pose.Y}
+	} else {
+		stroke.Points = append(stroke.Points, Point{o.Pose.X, o.Pose.Y})
+	}
+	o.turned = false
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestReconstructSimTrace(t *testing.T) {
+	_, r := runSimProgram(t, "PD\nREPEAT 3 [FD 1 LT 120]\nPU\nFD 2\n")
+	var buf bytes.Buffer
+	if err := r.WriteTrace(&buf); err != nil {
+		t.Fatal(err)
+	}
+	events, err := ReadTrace(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := ReconstructPath(events, 0)
+	if o.Skipped != 0 || o.Unknown != 0 {
+		t.Errorf("got %d skipped and %d unknown, want none", o.Skipped, o.Unknown)
+	}
+	if math.Abs(o.Pose.X-2) > 1e-6 || math.Abs(o.Pose.Y) > 1e-6 {
+		t.Errorf("ended at (%v, %v), want (2, 0)", o.Pose.X, o.Pose.Y)
+	}
+	var drawn []Point
+	for _, s := range o.Strokes {
+		if !s.PenUp {
+			drawn = append(drawn, s.Points...)
+		}
+	}
+	want := []Point{{0, 0}, {1, 0}, {0.5, math.Sqrt(3) / 2}, {0, 0}}
+	if len(drawn) != len(want) {
+		t.Fatalf("got drawn points %v, want %v", drawn, want)
+	}
+	for i := range want {
+		if math.Abs(drawn[i].X-want[i].X) > 1e-6 || math.Abs(drawn[i].Y-want[i].Y) > 1e-6 {
+			t.Errorf("point %d: got %v, want %v", i, drawn[i], want[i])
+		}
+	}
+}
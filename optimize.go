@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// maxTwoOpt is the most strokes 2-opt is tried on, since each pass looks at
+// every pair of them.
+const maxTwoOpt = 2000
+
+// path is a run of points drawn with the pen down, in the turtle's frame.
+type path []Point
+
+func (p path) first() Point { return p[0] }
+func (p path) last() Point  { return p[len(p)-1] }
+
+func (p path) reversed() path {
+	r := make(path, len(p))
+	for i, point := range p {
+		r[len(p)-1-i] = point
+	}
+	return r
+}
+
+func distance(a, b Point) float64 {
+	return math.Hypot(b.X-a.X, b.Y-a.Y)
+}
+
+// paths finds what d draws, leaving out turns and pen-up travel, with the
+// points in the middle of straight lines left out.
+func (d *DisplayList) paths() []path {
+	var paths []path
+	var current path
+	from := Point{d.Start.X, d.Start.Y}
+	for _, c := range d.Calls {
+		to := Point{c.State.X, c.State.Y}
+		if c.Steps != nil && !c.State.IsPenUp && distance(from, to) > 1e-9 {
+			if len(current) == 0 {
+				current = path{from}
+			}
+			current = appendStraight(current, to)
+		}
+		if c.State.IsPenUp && len(current) > 0 {
+			paths = append(paths, current)
+			current = nil
+		}
+		from = to
+	}
+	if len(current) > 0 {
+		paths = append(paths, current)
+	}
+	return paths
+}
+
+// appendStraight adds to to p, replacing the last point if it's on the way
+// there in a straight line.
+func appendStraight(p path, to Point) path {
+	if len(p) >= 2 {
+		a, b := p[len(p)-2], p[len(p)-1]
+		cross := (b.X-a.X)*(to.Y-b.Y) - (b.Y-a.Y)*(to.X-b.X)
+		dot := (b.X-a.X)*(to.X-b.X) + (b.Y-a.Y)*(to.Y-b.Y)
+		if math.Abs(cross) < 1e-9*distance(a, b)*distance(b, to) && dot > 0 {
+			p[len(p)-1] = to
+			return p
+		}
+	}
+	return append(p, to)
+}
+
+// order puts paths in the order, and each the way round, that keeps the
+// travel between them short, going from start to end. It picks the nearest
+// path each time, then reverses runs of paths while that helps (2-opt).
+func order(paths []path, start, end Point) []path {
+	left := append([]path(nil), paths...)
+	var ordered []path
+	at := start
+	for len(left) > 0 {
+		best, reverse, bestDistance := 0, false, math.Inf(1)
+		for i, p := range left {
+			if d := distance(at, p.first()); d < bestDistance {
+				best, reverse, bestDistance = i, false, d
+			}
+			if d := distance(at, p.last()); d < bestDistance {
+				best, reverse, bestDistance = i, true, d
+			}
+		}
+		p := left[best]
+		if reverse {
+			p = p.reversed()
+		}
+		ordered = append(ordered, p)
+		at = p.last()
+		left = append(left[:best], left[best+1:]...)
+	}
+
+	if len(ordered) > maxTwoOpt {
+		return ordered
+	}
+	for improved := true; improved; {
+		improved = false
+		for i := range ordered {
+			before := start
+			if i > 0 {
+				before = ordered[i-1].last()
+			}
+			for j := i + 1; j < len(ordered); j++ {
+				after := end
+				if j+1 < len(ordered) {
+					after = ordered[j+1].first()
+				}
+				now := distance(before, ordered[i].first()) + distance(ordered[j].last(), after)
+				swapped := distance(before, ordered[j].last()) + distance(ordered[i].first(), after)
+				if swapped < now-1e-9 {
+					for a, b := i, j; a < b; a, b = a+1, b-1 {
+						ordered[a], ordered[b] = ordered[b], ordered[a]
+					}
+					for k := i; k <= j; k++ {
+						ordered[k] = ordered[k].reversed()
+					}
+					improved = true
+				}
+			}
+		}
+	}
+	return ordered
+}
+
+// driveTo is lineTo for a point in the turtle's frame.
+func driveTo(t TurtleController, p Point) error {
+	x, y := LogoPos(BaseTurtle{X: p.X, Y: p.Y})
+	return lineTo(t, x, y)
+}
+
+// Optimize returns a display list that draws the same lines as d with less
+// travel in between, and ends up where d does. Lines may be drawn in another
+// order or the other way round, so it's for drawings where that doesn't
+// matter. A list with waits in it can't be reordered, since they would no
+// longer come between the same lines.
+func (d *DisplayList) Optimize() (*DisplayList, error) {
+	if len(d.Waits) > 0 {
+		return nil, fmt.Errorf("can't reorder a drawing that uses SLEEP; use -plan to keep its order")
+	}
+	end := d.BaseTurtle
+	paths := order(d.paths(), Point{d.Start.X, d.Start.Y}, Point{end.X, end.Y})
+	o := NewDisplayList(d.Start)
+	for _, p := range paths {
+		if err := setPen(o, true); err != nil {
+			return nil, err
+		}
+		if err := driveTo(o, p.first()); err != nil {
+			return nil, err
+		}
+		if err := setPen(o, false); err != nil {
+			return nil, err
+		}
+		for _, point := range p[1:] {
+			if err := driveTo(o, point); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := setPen(o, true); err != nil {
+		return nil, err
+	}
+	if err := driveTo(o, Point{end.X, end.Y}); err != nil {
+		return nil, err
+	}
+	if err := SetHeading(o, LogoHeading(end)); err != nil {
+		return nil, err
+	}
+	if err := setPen(o, end.IsPenUp); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"testing"
+	"time"
+)
+
+// plan runs src on a display list.
+func plan(t *testing.T, src string) *DisplayList {
+	t.Helper()
+	program, err := ParseString("", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := NewDisplayList(BaseTurtle{})
+	if err := NewContext(list, nil, ioutil.Discard, nil).RunProgram(program); err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestPathsMergeStraightLines(t *testing.T) {
+	paths := plan(t, "FD 5 FD 5 RT 90 FD 5 PU FD 5 PD BK 2 BK 2").paths()
+	if len(paths) != 2 || len(paths[0]) != 3 || len(paths[1]) != 2 {
+		t.Errorf("paths() = %v, want an L and a line", paths)
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	// Two rows of dashes, the second drawn from the same end as the first.
+	list := plan(t, `REPEAT 5 [PD FD 10 PU FD 10]
+PU HOME RT 90 FD 50 LT 90
+REPEAT 5 [PD FD 10 PU FD 10]
+RT 45`)
+	optimized, err := list.Optimize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, after := list.Estimate(), optimized.Estimate()
+	if math.Abs(after.Drawn-before.Drawn) > 1e-9 {
+		t.Errorf("Optimize() draws %v, want %v", after.Drawn, before.Drawn)
+	}
+	if after.Travel >= before.Travel {
+		t.Errorf("Optimize() travels %v with the pen up, want less than %v", after.Travel, before.Travel)
+	}
+	end, want := optimized.State(), list.State()
+	if math.Abs(end.X-want.X) > 1e-9 || math.Abs(end.Y-want.Y) > 1e-9 ||
+		math.Abs(LogoHeading(end)-LogoHeading(want)) > 1e-9 || end.IsPenUp != want.IsPenUp {
+		t.Errorf("Optimize() ends at %+v, want %+v", end, want)
+	}
+}
+
+func TestOptimizeRefusesWaits(t *testing.T) {
+	list := NewDisplayList(BaseTurtle{})
+	list.Move(10)
+	list.Sleep(time.Second)
+	list.Move(10)
+	if _, err := list.Optimize(); err == nil {
+		t.Errorf("Optimize() = nil, want an error for SLEEP")
+	}
+}
@@ -2,174 +2,272 @@ package main
 
 import (
 	"io"
+	"io/ioutil"
+	"strings"
 
 	"github.com/alecthomas/participle/v2"
 	"github.com/alecthomas/participle/v2/lexer"
 	"github.com/alecthomas/participle/v2/lexer/stateful"
 )
 
-/* Commands:*/
-/* SETHEADING SETH */
-/* HOME*/
-/* PENDOWNP PENDOWN?*/
-/* CLEAN*/
-/* CLEARSCREEN CS (HOME+CLEAN)*/
-/* REPEAT*/
-/* REPCOUNT*/
-/* IF*/
-/* IFELSE*/
-/* STOP*/
-/* OUTPUT*/
-
 /* Maybes:*/
-/* SETPOS*/
-/* SETXY */
-/* SETX */
-/* SETY */
 /* TEST*/
 /* IFTRUE*/
 /* IFFALSE*/
 var (
-	basicLexer = stateful.MustSimple([]stateful.Rule{
-		{"Comment", `(?i)rem[^\n]*`, nil},
-		{"String", `"(\\"|[^"])*"`, nil},
-		{"Number", `[-+]?(\d*\.)?\d+`, nil},
-		{"Ident", `[a-zA-Z_]\w*`, nil},
-		{"Punct", `[-[!@#$%^&*()+_={}\|:;"'<,>.?/]|]`, nil},
-		{"EOL", `[\n\r\d]+`, nil},
+	basicLexer = &logoLexerDefinition{stateful.MustSimple([]stateful.Rule{
+		{"Comment", `[;#][^\n]*`, nil},
+		{"Word", `"(\|([^|\\]|\\.)*\||[^\s\[\]()]*)`, nil},
+		{"Var", `:[a-zA-Z_][\w.?]*`, nil},
+		{"Punct", `<=|>=|<>|!=|[-+*/^=<>()\[\]]`, nil},
+		// Text is punctuation and the rest of the word after it, or a word
+		// between bars like |a b|. The lexer joins it onto a name or number
+		// just before it, so that Hello, in [Hello, world!] is one word. It
+		// only makes sense as data.
+		{"Text", `\|([^|\\]|\\.)*\||[,!:'@$%&{}~|\\\x60][^\s\[\]()";#]*`, nil},
+		{"Number", `(\d*\.)?\d+([eE][-+]?\d+)?`, nil},
+		{"Ident", `[a-zA-Z_?][\w.?]*`, nil},
+		{"EOL", `[\n\r]+`, nil},
 		{"whitespace", `[ \t]+`, nil},
-	})
+	})}
 
 	basicParser = participle.MustBuild(&Program{},
 		participle.Lexer(basicLexer),
 		participle.CaseInsensitive("Ident"),
-		participle.Unquote("String"),
+		participle.Elide("Comment"),
+		participle.Map(func(t lexer.Token) (lexer.Token, error) {
+			t.Value = t.Value[1:]
+			return t, nil
+		}, "Word", "Var"),
+		participle.Map(func(t lexer.Token) (lexer.Token, error) {
+			// "|a [b]| quotes a word with spaces or brackets in it.
+			t.Value, _ = unbar(t.Value)
+			return t, nil
+		}, "Word"),
 		participle.UseLookahead(2),
 	)
 )
 
-func Parse(r io.Reader) (*Program, error) {
-	program := &Program{}
-	err := basicParser.Parse("", r, program)
-	if err != nil {
-		return nil, err
-	}
-
-	return program, nil
+// logoLexerDefinition marks a "-" as unary minus the way UCBLogo does, when it
+// sticks to what follows and comes after a space, an operator or the start
+// of a line. That keeps SETXY -10 20 apart from :x - 10. It also marks the END
+// of a procedure, which is only END at the start of a line after a TO, so that
+// [the end] is just words.
+type logoLexerDefinition struct {
+	rules *stateful.Definition
 }
 
-//////////
-////////// Commands
-//////////
-type Forward struct {
-	Pos lexer.Position
-
-	Expression Expression `("FORWARD" | "FD") @@`
+func (d *logoLexerDefinition) Symbols() map[string]rune {
+	symbols := map[string]rune{}
+	next := rune(0)
+	for name, r := range d.rules.Symbols() {
+		symbols[name] = r
+		if r < next {
+			next = r
+		}
+	}
+	symbols["Neg"] = next - 1
+	symbols["End"] = next - 2
+	return symbols
 }
 
-type Backward struct {
-	Pos lexer.Position
-
-	Expression Expression `("BACKWARD" | "BK") @@`
+func (d *logoLexerDefinition) Lex(filename string, r io.Reader) (lexer.Lexer, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.LexString(filename, string(src))
 }
 
-type Right struct {
-	Pos lexer.Position
-
-	Expression Expression `("RIGHT" | "RT") @@`
+func (d *logoLexerDefinition) LexString(filename string, src string) (lexer.Lexer, error) {
+	l, err := d.rules.LexString(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	symbols := d.Symbols()
+	return &logoLexer{
+		Lexer: l,
+		neg:   symbols["Neg"],
+		end:   symbols["End"],
+		punct: symbols["Punct"],
+		ident: symbols["Ident"],
+		num:   symbols["Number"],
+		text:  symbols["Text"],
+		eol:   symbols["EOL"],
+	}, nil
 }
 
-type Left struct {
-	Pos lexer.Position
-
-	Expression Expression `("LEFT" | "LT") @@`
+type logoLexer struct {
+	lexer.Lexer
+	neg, end, punct, ident, num, text, eol rune
+	prev                                   *lexer.Token
+	peeked                                 *lexer.Token
+	// procedures is how many TOs are waiting for their END.
+	procedures int
 }
 
-type Sleep struct {
-	Pos lexer.Position
-
-	Expression Expression `("SLEEP" | "SP") @@`
+func (l *logoLexer) read() (lexer.Token, error) {
+	if l.peeked != nil {
+		t := *l.peeked
+		l.peeked = nil
+		return t, nil
+	}
+	return l.Lexer.Next()
 }
 
-type PenUp struct {
-	Pos   lexer.Position
-	Ident bool `("PENUP" | "PU")`
+func (l *logoLexer) peek() (lexer.Token, error) {
+	if l.peeked == nil {
+		next, err := l.Lexer.Next()
+		if err != nil {
+			return next, err
+		}
+		l.peeked = &next
+	}
+	return *l.peeked, nil
 }
 
-type PenDown struct {
-	Pos   lexer.Position
-	Ident bool `("PENDOWN" | "PD")`
+func (l *logoLexer) Next() (lexer.Token, error) {
+	t, err := l.read()
+	if err != nil {
+		return t, err
+	}
+	if t.Type == l.ident || t.Type == l.num {
+		next, err := l.peek()
+		if err != nil {
+			return t, err
+		}
+		if next.Type == l.text && next.Pos.Offset == t.Pos.Offset+len(t.Value) {
+			t.Type = l.text
+			t.Value += next.Value
+			l.peeked = nil
+		}
+	}
+	if t.Type == l.punct && t.Value == "-" {
+		next, err := l.peek()
+		if err != nil {
+			return t, err
+		}
+		attached := !next.EOF() && next.Type != l.eol && next.Pos.Offset == t.Pos.Offset+1 &&
+			!(next.Type == l.punct && next.Value != "(" && next.Value != "[")
+		if attached && l.startsOperand(t) {
+			t.Type = l.neg
+		}
+	}
+	lineStart := l.prev == nil || l.prev.Type == l.eol
+	switch {
+	case t.Type == l.ident && lineStart && strings.EqualFold(t.Value, "TO"):
+		l.procedures++
+	case t.Type == l.ident && lineStart && strings.EqualFold(t.Value, "END") && l.procedures > 0:
+		l.procedures--
+		t.Type = l.end
+	}
+	l.prev = &t
+	return t, nil
 }
 
-type Repeat struct {
-	Pos      lexer.Position
-	Times    *Expression `"REPEAT" @@`
-	Commands []Command   `"[" @@+ "]"`
+// startsOperand reports whether t is in a place where an operand, not an
+// infix operator, is expected.
+func (l *logoLexer) startsOperand(t lexer.Token) bool {
+	prev := l.prev
+	switch {
+	case prev == nil, prev.Type == l.eol:
+		return true
+	case prev.Type == l.punct && prev.Value != ")" && prev.Value != "]":
+		return true
+	}
+	return prev.Pos.Offset+len(prev.Value) < t.Pos.Offset
 }
 
-type Comment struct {
-	Pos      lexer.Position
-	Commands []Command `"#" @@*`
+// Parse reads a whole program. The last line doesn't need a newline.
+func Parse(r io.Reader) (*Program, error) {
+	return ParseNamed("", r)
 }
 
-type Stop struct {
-	Pos   lexer.Position
-	Ident bool `"STOP"`
+// ParseNamed is Parse with a file name for positions in error messages.
+func ParseNamed(filename string, r io.Reader) (*Program, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseString(filename, string(src))
 }
 
-/* SETHEADING SETH */
-/* HOME*/
-/* PENDOWNP PENDOWN?*/
-/* CLEAN*/
-/* CLEARSCREEN CS (HOME+CLEAN)*/
-/* REPEAT*/
-/* REPCOUNT*/
-/* IF*/
-/* IFELSE*/
-/* STOP*/
-/* OUTPUT*/
-
-type Command struct {
-	Pos lexer.Position
-
-	Index int
-
-	//	Line int `@Number`
-
-	Forward  *Forward  `( @@ |`
-	Backward *Backward ` @@ |`
-	Right    *Right    ` @@ |`
-	Left     *Left     ` @@ |`
-	PenUp    *PenUp    ` @@ |`
-	PenDown  *PenDown  ` @@ |`
-	Repeat   *Repeat   ` @@ |`
-	Sleep    *Sleep    ` @@ |`
-	Comment  *Comment  ` @@ |`
-	Stop     *Stop     ` @@)`
+func ParseString(filename, src string) (*Program, error) {
+	if !strings.HasSuffix(src, "\n") {
+		src += "\n"
+	}
+	program := &Program{}
+	err := basicParser.ParseString(filename, src, program)
+	if err != nil {
+		return nil, err
+	}
+	program.Comments, err = comments(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	return program, nil
+}
 
-	// 	Remark *Remark `(   @@`
-	// 	Input  *Input  `  | @@`
-	// 	Let    *Let    `  | @@`
-	// 	Goto   *Goto   `  | @@`
-	// 	If     *If     `  | @@`
-	// 	Print  *Print  `  | @@`
-	// 	Call   *Call   `  | @@ ) EOL`
+// comments finds the comments in src, which the parser skips over.
+func comments(filename, src string) ([]*Comment, error) {
+	l, err := basicLexer.LexString(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	comment := basicLexer.Symbols()["Comment"]
+	var out []*Comment
+	for {
+		t, err := l.Next()
+		if err != nil {
+			return nil, err
+		}
+		if t.EOF() {
+			return out, nil
+		}
+		if t.Type == comment {
+			out = append(out, &Comment{Pos: t.Pos, Text: strings.TrimRight(t.Value, " \t\r")})
+		}
+	}
 }
 
 ///////////////////////////
 /////////////////////////// Program Structure
 ///////////////////////////
+
+// To defines a procedure:
+//
+//	TO name :input1 :input2
+//	  instructions
+//	END
+type To struct {
+	Pos    lexer.Position
+	EndPos lexer.Position
+
+	Name   string   `"TO" @Ident`
+	Params []string `@Var* EOL`
+	Body   []*Line  `( @@ | EOL )* End`
+}
+
+// Line is either a procedure definition or a line of instructions. Which
+// expressions belong to which instruction isn't known until the procedures
+// they call, and so their number of inputs, are.
 type Line struct {
-	Command *Command `@@ EOL`
+	Pos lexer.Position
+
+	To    *To           `  @@`
+	Items []*Expression `| @@+ EOL`
 }
 
 type Program struct {
 	Pos lexer.Position
 
-	Commands []Command `(@@ EOL)*`
+	Lines []*Line `( @@ | EOL )*`
+	// Comments aren't part of the grammar, but are kept for jlogo fmt.
+	Comments []*Comment
 }
 
-/* FORWARD FD */
-/* BACK BK */
-/* RIGHT RT */
-/* LEFT LT */
+// Comment is a ; or # comment running to the end of the line.
+type Comment struct {
+	Pos  lexer.Position
+	Text string
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	runResults(t, []resultTest{
+		{"[Hello, world!]", "[Hello, world!]"},
+		{"[it's 3, or $5: {maybe}]", "[it's 3, or $5: {maybe}]"},
+		{"[the end]", "[the end]"},
+		{"TO F\nOUTPUT [end]\n  END\nF", "[end]"},
+		{"TO F :x\nOUTPUT :x * 2\nEND ; of F\nF 3", "6"},
+		{"# a comment\n1 ; another", "1"},
+		{"IF 1!=2 [\"yes]", "yes"},
+		{"1-2", "-1"},
+		{"1 - -2", "3"},
+	})
+}
+
+func TestBarQuotes(t *testing.T) {
+	runResults(t, []resultTest{
+		{"\"|a [b]|", "a [b]"},
+		{"COUNT \"|a b|", "3"},
+		{"WORD \"|(| \"x", "(x"},
+		{"\"||", ""},
+		// Inside a list the word keeps its quote, and so its bars.
+		{"[\"|a b| c]", "[\"|a b| c]"},
+		// Without the quote it's the word between the bars.
+		{"COUNT FIRST [|a b| c]", "3"},
+		{"COUNT [|x]| |;| ||]", "3"},
+		// A backslash lets a bar or a backslash into the word.
+		{"\"|a\\|b\\\\c|", "a|b\\c"},
+		{"FIRST [|\\|x\\|| c]", "|x|"},
+	})
+}
+
+func TestEndOutsideTo(t *testing.T) {
+	if _, err := result("END"); err == nil || !strings.Contains(err.Error(), "I don't know how to END") {
+		t.Errorf("Run(END) = %v, want an unknown procedure", err)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runSimProgram runs src end to end through a PiTurtle on simulated pins.
+func runSimProgram(t *testing.T, src string) (*PiTurtle, *SimRecorder) {
+	t.Helper()
+	r := NewSimRecorder()
+	turtle, err := NewSimPiTurtle(ioutil.Discard, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := program.Evaluate(turtle, strings.NewReader(""), ioutil.Discard, map[string]Function{}); err != nil {
+		t.Fatal(err)
+	}
+	return turtle, r
+}
+
+// phaseStep is the stepper phase a wheel was in from At onwards.
+type phaseStep struct {
+	At    time.Duration
+	Phase int
+}
+
+// wheelPhases replays the pin history of a wheel and decodes each state it
+// settles in back into an index of StandardStepperPattern.
+func wheelPhases(t *testing.T, r *SimRecorder, pins []int) []phaseStep {
+	t.Helper()
+	index := map[int]int{}
+	for i, pin := range pins {
+		index[pin] = i
+	}
+	state := make([]bool, len(pins))
+	var phases []phaseStep
+	flush := func(at time.Duration) {
+		for phase, pattern := range StandardStepperPattern {
+			if reflect.DeepEqual(pattern, state) {
+				phases = append(phases, phaseStep{At: at, Phase: phase})
+				return
+			}
+		}
+		t.Fatalf("pins %v at %v match no phase", state, at)
+	}
+	var (
+		pending bool
+		at      time.Duration
+	)
+	for _, e := range r.Pins {
+		i, ok := index[e.Pin]
+		if !ok {
+			continue
+		}
+		if pending && e.At != at {
+			flush(at)
+		}
+		state[i] = e.Value
+		pending, at = true, e.At
+	}
+	if pending {
+		flush(at)
+	}
+	return phases
+}
+
+func phaseIndexes(steps []phaseStep) []int {
+	var phases []int
+	for _, s := range steps {
+		phases = append(phases, s.Phase)
+	}
+	return phases
+}
+
+func TestSimPenDutyCycles(t *testing.T) {
+	_, r := runSimProgram(t, "PD\nPU\nPENDOWN\n")
+	want := []DutyCycleEvent{
+		{At: 0, Pin: PinPenServo, DutyCycle: 0.2},
+		{At: 2 * time.Millisecond, Pin: PinPenServo, DutyCycle: 0.05},
+		{At: 4 * time.Millisecond, Pin: PinPenServo, DutyCycle: 0.2},
+	}
+	if len(r.DutyCycles) != len(want) {
+		t.Fatalf("got %d duty cycles %v, want %v", len(r.DutyCycles), r.DutyCycles, want)
+	}
+	for i := range want {
+		got := r.DutyCycles[i]
+		if got.At != want[i].At || got.Pin != want[i].Pin || math.Abs(got.DutyCycle-want[i].DutyCycle) > 1e-9 {
+			t.Errorf("duty cycle %d: got %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestSimForwardPhases(t *testing.T) {
+	_, r := runSimProgram(t, "FD 0.1\n")
+	want := []int{1, 2, 3, 4, 5, 6, 7, 8, 0, 1}
+	for _, pins := range [][]int{PinsLeftWheel, PinsRightWheel} {
+		steps := wheelPhases(t, r, pins)
+		if got := phaseIndexes(steps); !reflect.DeepEqual(got, want) {
+			t.Errorf("wheel %v: got phases %v, want %v", pins, got, want)
+		}
+		for i, s := range steps {
+			if want := time.Duration(i) * 2 * time.Millisecond; s.At != want {
+				t.Errorf("wheel %v step %d: at %v, want %v", pins, i, s.At, want)
+			}
+		}
+	}
+}
+
+func TestSimBackwardPhases(t *testing.T) {
+	// The first step lands on phase 8, which is all pins off just like at
+	// power on, so it doesn't show up as a transition.
+	_, r := runSimProgram(t, "BK 0.05\n")
+	want := []int{7, 6, 5, 4}
+	for _, pins := range [][]int{PinsLeftWheel, PinsRightWheel} {
+		if got := phaseIndexes(wheelPhases(t, r, pins)); !reflect.DeepEqual(got, want) {
+			t.Errorf("wheel %v: got phases %v, want %v", pins, got, want)
+		}
+	}
+}
+
+func TestSimTurnPhases(t *testing.T) {
+	// RIGHT 1 is 23 stepper steps with the wheels turning in opposite directions.
+	_, r := runSimProgram(t, "RT 1\n")
+	left := phaseIndexes(wheelPhases(t, r, PinsLeftWheel))
+	right := phaseIndexes(wheelPhases(t, r, PinsRightWheel))
+	// As in TestSimBackwardPhases the left wheel's first step is invisible.
+	if len(left) != 22 || len(right) != 23 {
+		t.Fatalf("got %d left and %d right steps, want 22 and 23", len(left), len(right))
+	}
+	if want := []int{7, 6, 5, 4, 3, 2, 1, 0, 8, 7}; !reflect.DeepEqual(left[:10], want) {
+		t.Errorf("left wheel: got phases %v, want %v", left[:10], want)
+	}
+	if want := []int{1, 2, 3, 4, 5, 6, 7, 8, 0, 1}; !reflect.DeepEqual(right[:10], want) {
+		t.Errorf("right wheel: got phases %v, want %v", right[:10], want)
+	}
+}
+
+func TestSimSquareReturnsHome(t *testing.T) {
+	turtle, r := runSimProgram(t, "PD\nREPEAT 4 [FD 1 RT 90]\nPU\n")
+	state := turtle.State()
+	if math.Abs(state.X) > 1e-9 || math.Abs(state.Y) > 1e-9 {
+		t.Errorf("got position (%v, %v), want (0, 0)", state.X, state.Y)
+	}
+	if !state.IsPenUp {
+		t.Errorf("pen is down, want up")
+	}
+	// Each side is 100 steps forward followed by 90*23 turning steps.
+	wantSteps := 4 * (100 + 90*23)
+	if got := len(wheelPhases(t, r, PinsLeftWheel)); got != wantSteps {
+		t.Errorf("got %d left wheel steps, want %d", got, wantSteps)
+	}
+	if got, want := r.Clock.Now(), time.Duration(wantSteps+2)*2*time.Millisecond; got != want {
+		t.Errorf("program took %v, want %v", got, want)
+	}
+}
+
+func TestSimGPIOError(t *testing.T) {
+	r := NewSimRecorder()
+	turtle, err := NewSimPiTurtle(ioutil.Discard, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errBroken := errors.New("broken pin")
+	turtle.RightWheel.(*GPIOStepper).Pins[2].(*SimGPIO).Err = errBroken
+	if _, _, err := turtle.Move(1); err != errBroken {
+		t.Errorf("got error %v, want %v", err, errBroken)
+	}
+	if state := turtle.State(); state.X != 0 || state.Y != 0 {
+		t.Errorf("turtle moved to (%v, %v) after a failed step", state.X, state.Y)
+	}
+}
+
+func TestSimCatchGPIOError(t *testing.T) {
+	r := NewSimRecorder()
+	turtle, err := NewSimPiTurtle(ioutil.Discard, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	turtle.RightWheel.(*GPIOStepper).Pins[2].(*SimGPIO).Err = errors.New("broken pin")
+	program, err := Parse(strings.NewReader("PD\nCATCH \"ERROR [FD 1]\nPU\nMAKE \"E ERROR\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewContext(turtle, nil, ioutil.Discard, nil)
+	if err := ctx.RunProgram(program); err != nil {
+		t.Fatalf("got %v, want the error caught", err)
+	}
+	if !turtle.State().IsPenUp {
+		t.Errorf("pen is down, want up")
+	}
+	if got, want := FormatValue(ctx.Vars["e"]), "[[FD failed: broken pin] 2 15]"; got != want {
+		t.Errorf("got ERROR %s, want %s", got, want)
+	}
+}
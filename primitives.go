@@ -0,0 +1,397 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Primitive is a procedure built into the interpreter.
+type Primitive struct {
+	// Inputs is how many inputs the primitive takes when called without
+	// parentheses. In parentheses it takes anything from MinInputs to
+	// MaxInputs, or any number if MaxInputs is -1.
+	Inputs, MinInputs, MaxInputs int
+	Fn                           func(ctx *Context, in *Inputs) (interface{}, error)
+}
+
+// Inputs are the evaluated inputs of a single primitive call.
+type Inputs struct {
+	Pos    lexer.Position
+	Name   string
+	Values []interface{}
+	// Positions of the values in the program, when they were written there
+	// rather than handed over by APPLY or MAP.
+	Positions []lexer.Position
+}
+
+// primitives are keyed by upper case name. Each file registers its own in
+// init.
+var primitives = map[string]*Primitive{}
+
+// fullNames maps each name of a primitive to the first, so FD to FORWARD.
+var fullNames = map[string]string{}
+
+// definePrimitive registers p under each of names, the full name first and
+// then any abbreviations.
+func definePrimitive(p *Primitive, names ...string) {
+	for _, name := range names {
+		primitives[strings.ToUpper(name)] = p
+		fullNames[strings.ToUpper(name)] = strings.ToUpper(names[0])
+	}
+}
+
+// fixed is a primitive that always takes n inputs.
+func fixed(n int, fn func(ctx *Context, in *Inputs) (interface{}, error)) *Primitive {
+	return &Primitive{Inputs: n, MinInputs: n, MaxInputs: n, Fn: fn}
+}
+
+// variadic is a primitive that takes n inputs, or at least min in parentheses.
+func variadic(n, min int, fn func(ctx *Context, in *Inputs) (interface{}, error)) *Primitive {
+	return &Primitive{Inputs: n, MinInputs: min, MaxInputs: -1, Fn: fn}
+}
+
+func (in *Inputs) errorf(format string, args ...interface{}) error {
+	return errorf(in.Pos, format, args...)
+}
+
+// at is where input i is in the program, or else where the call is.
+func (in *Inputs) at(i int) lexer.Position {
+	if i < len(in.Positions) {
+		return in.Positions[i]
+	}
+	return in.Pos
+}
+
+func (in *Inputs) doesntLike(i int) error {
+	return errorf(in.at(i), "%s doesn't like %s as input", in.Name, FormatValue(in.Values[i]))
+}
+
+func (in *Inputs) Number(i int) (float64, error) {
+	n, ok := toNumber(in.Values[i])
+	if !ok {
+		return 0, in.doesntLike(i)
+	}
+	return n, nil
+}
+
+func (in *Inputs) Bool(i int) (bool, error) {
+	b, ok := toBool(in.Values[i])
+	if !ok {
+		return false, errorf(in.at(i), "%s doesn't like %s as input, it needs true or false", in.Name, FormatValue(in.Values[i]))
+	}
+	return b, nil
+}
+
+func (in *Inputs) Word(i int) (string, error) {
+	w, ok := toWord(in.Values[i])
+	if !ok {
+		return "", in.doesntLike(i)
+	}
+	return w, nil
+}
+
+func (in *Inputs) List(i int) (List, error) {
+	l, ok := in.Values[i].(List)
+	if !ok {
+		return List{}, in.doesntLike(i)
+	}
+	return l, nil
+}
+
+// turtleError gives an error from the turtle, such as a failed GPIO write, the
+// position of the call, so that it can be caught like any other.
+func (in *Inputs) turtleError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return wrapf(in.Pos, err, "%s failed", in.Name)
+}
+
+// Numbers converts every input to a number.
+func (in *Inputs) Numbers() ([]float64, error) {
+	numbers := make([]float64, len(in.Values))
+	for i := range in.Values {
+		n, err := in.Number(i)
+		if err != nil {
+			return nil, err
+		}
+		numbers[i] = n
+	}
+	return numbers, nil
+}
+
+func init() {
+	// Turtle motion.
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		steps, err := in.Number(0)
+		if err != nil {
+			return nil, err
+		}
+		_, _, err = ctx.Turtle.Move(steps)
+		return nil, in.turtleError(err)
+	}), "FORWARD", "FD")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		steps, err := in.Number(0)
+		if err != nil {
+			return nil, err
+		}
+		_, _, err = ctx.Turtle.Move(-steps)
+		return nil, in.turtleError(err)
+	}), "BACK", "BK", "BACKWARD")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		deg, err := in.Number(0)
+		if err != nil {
+			return nil, err
+		}
+		_, err = ctx.Turtle.Rotate(-deg)
+		return nil, in.turtleError(err)
+	}), "RIGHT", "RT")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		deg, err := in.Number(0)
+		if err != nil {
+			return nil, err
+		}
+		_, err = ctx.Turtle.Rotate(deg)
+		return nil, in.turtleError(err)
+	}), "LEFT", "LT")
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		_, err := ctx.Turtle.PenUp(true)
+		return nil, in.turtleError(err)
+	}), "PENUP", "PU")
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		_, err := ctx.Turtle.PenUp(false)
+		return nil, in.turtleError(err)
+	}), "PENDOWN", "PD")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		ms, err := in.Number(0)
+		if err != nil {
+			return nil, err
+		}
+		ctx.Sleep(time.Millisecond * time.Duration(ms))
+		return nil, nil
+	}), "SLEEP", "SP")
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		if err := MoveTo(ctx.Turtle, 0, 0); err != nil {
+			return nil, in.turtleError(err)
+		}
+		return nil, in.turtleError(SetHeading(ctx.Turtle, 0))
+	}), "HOME")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		heading, err := in.Number(0)
+		if err != nil {
+			return nil, err
+		}
+		return nil, in.turtleError(SetHeading(ctx.Turtle, heading))
+	}), "SETHEADING", "SETH")
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		pos, err := in.Numbers()
+		if err != nil {
+			return nil, err
+		}
+		return nil, in.turtleError(MoveTo(ctx.Turtle, pos[0], pos[1]))
+	}), "SETXY")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		list, err := in.List(0)
+		if err != nil {
+			return nil, err
+		}
+		if len(list.Items) != 2 {
+			return nil, in.doesntLike(0)
+		}
+		x, okX := toNumber(list.Items[0])
+		y, okY := toNumber(list.Items[1])
+		if !okX || !okY {
+			return nil, in.doesntLike(0)
+		}
+		return nil, in.turtleError(MoveTo(ctx.Turtle, x, y))
+	}), "SETPOS")
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		ctx.pushTurtle()
+		return nil, nil
+	}), "PUSHTURTLE")
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		if len(ctx.turtles) == 0 {
+			return nil, in.errorf("%s has nothing to go back to, there's no PUSHTURTLE", in.Name)
+		}
+		return nil, in.turtleError(ctx.popTurtle())
+	}), "POPTURTLE")
+
+	// Turtle state.
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		x, _ := LogoPos(ctx.Turtle.State())
+		return x, nil
+	}), "XCOR")
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		_, y := LogoPos(ctx.Turtle.State())
+		return y, nil
+	}), "YCOR")
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		x, y := LogoPos(ctx.Turtle.State())
+		return NewList(x, y), nil
+	}), "POS")
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		return LogoHeading(ctx.Turtle.State()), nil
+	}), "HEADING")
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		return !ctx.Turtle.State().IsPenUp, nil
+	}), "PENDOWNP", "PENDOWN?")
+
+	// Control.
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		times, err := in.Number(0)
+		if err != nil {
+			return nil, err
+		}
+		saved := ctx.repcount
+		defer func() { ctx.repcount = saved }()
+		for i := 1; float64(i) <= times; i++ {
+			ctx.repcount = i
+			if _, err := ctx.RunValue(in, 1, false); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}), "REPEAT")
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		return float64(ctx.repcount), nil
+	}), "REPCOUNT")
+	definePrimitive(&Primitive{Inputs: 2, MinInputs: 2, MaxInputs: 3, Fn: func(ctx *Context, in *Inputs) (interface{}, error) {
+		cond, err := in.Bool(0)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case cond:
+			return ctx.RunValue(in, 1, true)
+		case len(in.Values) == 3:
+			return ctx.RunValue(in, 2, true)
+		}
+		return nil, nil
+	}}, "IF")
+	definePrimitive(fixed(3, func(ctx *Context, in *Inputs) (interface{}, error) {
+		cond, err := in.Bool(0)
+		if err != nil {
+			return nil, err
+		}
+		if cond {
+			return ctx.RunValue(in, 1, true)
+		}
+		return ctx.RunValue(in, 2, true)
+	}), "IFELSE")
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		return nil, &stopSignal{Pos: in.Pos}
+	}), "STOP")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		return nil, &outputSignal{Pos: in.Pos, Value: in.Values[0]}
+	}), "OUTPUT", "OP")
+
+	// Variables.
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		name, err := in.Word(0)
+		if err != nil {
+			return nil, err
+		}
+		ctx.setVar(name, in.Values[1])
+		return nil, nil
+	}), "MAKE")
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		name, err := in.Word(1)
+		if err != nil {
+			return nil, err
+		}
+		ctx.setVar(name, in.Values[0])
+		return nil, nil
+	}), "NAME")
+	definePrimitive(variadic(1, 1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		for i := range in.Values {
+			if list, ok := in.Values[i].(List); ok {
+				for _, item := range list.Items {
+					name, ok := toWord(item)
+					if !ok {
+						return nil, in.doesntLike(i)
+					}
+					ctx.declareLocal(name)
+				}
+				continue
+			}
+			name, err := in.Word(i)
+			if err != nil {
+				return nil, err
+			}
+			ctx.declareLocal(name)
+		}
+		return nil, nil
+	}), "LOCAL")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		name, err := in.Word(0)
+		if err != nil {
+			return nil, err
+		}
+		value, ok := ctx.lookup(name)
+		if !ok {
+			return nil, in.errorf("%s has no value", name)
+		}
+		return value, nil
+	}), "THING")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		name, err := in.Word(0)
+		if err != nil {
+			return nil, err
+		}
+		_, ok := ctx.lookup(name)
+		return ok, nil
+	}), "NAMEP", "NAME?")
+
+	// Arithmetic.
+	definePrimitive(variadic(2, 0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		numbers, err := in.Numbers()
+		if err != nil {
+			return nil, err
+		}
+		sum := 0.0
+		for _, n := range numbers {
+			sum += n
+		}
+		return sum, nil
+	}), "SUM")
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		numbers, err := in.Numbers()
+		if err != nil {
+			return nil, err
+		}
+		return numbers[0] - numbers[1], nil
+	}), "DIFFERENCE")
+	definePrimitive(variadic(2, 0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		numbers, err := in.Numbers()
+		if err != nil {
+			return nil, err
+		}
+		product := 1.0
+		for _, n := range numbers {
+			product *= n
+		}
+		return product, nil
+	}), "PRODUCT")
+	definePrimitive(&Primitive{Inputs: 2, MinInputs: 1, MaxInputs: 2, Fn: func(ctx *Context, in *Inputs) (interface{}, error) {
+		numbers, err := in.Numbers()
+		if err != nil {
+			return nil, err
+		}
+		if len(numbers) == 1 {
+			numbers = []float64{1, numbers[0]}
+		}
+		if numbers[1] == 0 {
+			return nil, in.errorf("%s can't divide by zero", in.Name)
+		}
+		return numbers[0] / numbers[1], nil
+	}}, "QUOTIENT")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		n, err := in.Number(0)
+		if err != nil {
+			return nil, err
+		}
+		return -n, nil
+	}), "MINUS")
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// write prints every input to ctx.Output, formatted with format, separated by
+// sep and followed by end.
+func write(ctx *Context, in *Inputs, format func(interface{}) string, sep, end string) (interface{}, error) {
+	words := make([]string, len(in.Values))
+	for i, value := range in.Values {
+		words[i] = format(value)
+	}
+	_, err := fmt.Fprint(ctx.Output, strings.Join(words, sep)+end)
+	if err != nil {
+		return nil, in.errorf("%s can't write output: %s", in.Name, err)
+	}
+	return nil, nil
+}
+
+func init() {
+	definePrimitive(variadic(1, 0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		return write(ctx, in, PrintValue, " ", "\n")
+	}), "PRINT", "PR")
+	definePrimitive(variadic(1, 0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		return write(ctx, in, FormatValue, " ", "\n")
+	}), "SHOW")
+	definePrimitive(variadic(1, 0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		return write(ctx, in, PrintValue, "", "")
+	}), "TYPE")
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPrint(t *testing.T) {
+	runResults(t, []resultTest{
+		{"PRINT \"hello", "hello\n"},
+		{"PRINT [a [b c] d]", "a [b c] d\n"},
+		{"SHOW [a [b c] d]", "[a [b c] d]\n"},
+		{"(PRINT 1 [2 3] \"four)", "1 2 3 four\n"},
+		{"(SHOW 1 [2 3] \"four)", "1 [2 3] four\n"},
+		{"TYPE \"a TYPE [b c] PRINT \"d", "ab cd\n"},
+		{"PRINT 1 / 3", "0.333333333333333\n"},
+		{"PRINT 1e20 PRINT 2.5 PRINT -0", "1e+20\n2.5\n0\n"},
+		{"PRINT 1 = 1 SHOW []", "true\n[]\n"},
+		{"REPEAT 4 [FD 10 RT 90] SHOW POS PRINT XCOR", "[0 0]\n0\n"},
+		{"RT 30 FD 2 PRINT YCOR", "1.73205080756888\n"},
+		// A result follows whatever was printed before it.
+		{"PRINT \"a\n\"b", "a\nb"},
+	})
+}
+
+func TestNoInputs(t *testing.T) {
+	runResults(t, []resultTest{
+		{"(PRINT) PRINT \"a", "\na\n"},
+		{"(LIST)", "[]"},
+		{"(POS)", "[0 0]"},
+	})
+	runErrors(t, []resultTest{
+		{"(FD)", "1:2: not enough inputs to FD"},
+	})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Random is a seeded source of random numbers. Running a program again with
+// the same seed draws the same picture.
+type Random struct {
+	*rand.Rand
+	Seed int64
+}
+
+func NewRandom(seed int64) *Random {
+	return &Random{Rand: rand.New(rand.NewSource(seed)), Seed: seed}
+}
+
+// Reseed restarts the sequence of random numbers from seed.
+func (r *Random) Reseed(seed int64) {
+	r.Seed = seed
+	r.Rand.Seed(seed)
+}
+
+func init() {
+	definePrimitive(&Primitive{Inputs: 1, MinInputs: 1, MaxInputs: 2, Fn: func(ctx *Context, in *Inputs) (interface{}, error) {
+		numbers, err := in.Numbers()
+		if err != nil {
+			return nil, err
+		}
+		start, end := 0.0, numbers[0]-1
+		if len(numbers) == 2 {
+			start, end = numbers[0], numbers[1]
+		}
+		for i, n := range numbers {
+			if n != math.Trunc(n) || math.Abs(n) >= 1<<63 {
+				return nil, in.doesntLike(i)
+			}
+		}
+		// The range has to fit in an int64 for Int63n.
+		if end < start || end-start >= 1<<63 {
+			return nil, in.doesntLike(len(numbers) - 1)
+		}
+		return start + float64(ctx.Random.Int63n(int64(end-start)+1)), nil
+	}}, "RANDOM")
+	definePrimitive(&Primitive{Inputs: 0, MinInputs: 0, MaxInputs: 1, Fn: func(ctx *Context, in *Inputs) (interface{}, error) {
+		seed := ctx.Random.Seed
+		if len(in.Values) == 1 {
+			n, err := in.Number(0)
+			if err != nil {
+				return nil, err
+			}
+			seed = int64(n)
+		}
+		ctx.Random.Reseed(seed)
+		return nil, nil
+	}}, "RERANDOM")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		items, _, err := in.nonEmpty(0)
+		if err != nil {
+			return nil, err
+		}
+		return items[ctx.Random.Intn(len(items))], nil
+	}), "PICK")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		items, isWord, err := in.sequence(0)
+		if err != nil {
+			return nil, err
+		}
+		shuffled := append([]interface{}{}, items...)
+		ctx.Random.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		return rebuild(shuffled, isWord), nil
+	}), "SHUFFLE")
+}
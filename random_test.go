@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// seeded is result with random numbers seeded by seed.
+func seeded(t *testing.T, src string, seed int64) string {
+	ctx := NewContext(NewTextTurtle(ioutil.Discard), nil, ioutil.Discard, nil)
+	ctx.Random = NewRandom(seed)
+	got, err := resultIn(ctx, src)
+	if err != nil {
+		t.Fatalf("Run(%q) = %v", src, err)
+	}
+	return got
+}
+
+func TestRandomRepeats(t *testing.T) {
+	tests := []string{
+		"(LIST RANDOM 100 RANDOM 100 RANDOM 100 RANDOM 100)",
+		"(LIST (RANDOM -5 5) (RANDOM -5 5) (RANDOM -5 5))",
+		"(LIST PICK [a b c d] PICK [a b c d] PICK [a b c d])",
+		"LIST SHUFFLE [1 2 3 4 5 6] SHUFFLE \"abcdef",
+	}
+	for _, src := range tests {
+		if a, b := seeded(t, src, 42), seeded(t, src, 42); a != b {
+			t.Errorf("Run(%q) with the same seed gave %q then %q", src, a, b)
+		}
+	}
+	src := "MAKE \"a (LIST RANDOM 1000 RANDOM 1000 RANDOM 1000) RERANDOM\n:a = (LIST RANDOM 1000 RANDOM 1000 RANDOM 1000)"
+	if got := seeded(t, src, 7); got != "true" {
+		t.Errorf("RERANDOM didn't start again")
+	}
+	src = "(RERANDOM 3) RANDOM 1000"
+	if a, b := seeded(t, src, 1), seeded(t, src, 2); a != b {
+		t.Errorf("(RERANDOM 3) gave %q and %q", a, b)
+	}
+}
+
+func TestRandomRange(t *testing.T) {
+	src := "MAKE \"s [] REPEAT 200 [MAKE \"s FPUT (RANDOM 3 5) :s] :s"
+	seen := map[string]bool{}
+	for _, n := range strings.Fields(strings.Trim(seeded(t, src, 1), "[]")) {
+		seen[n] = true
+	}
+	if len(seen) != 3 || !seen["3"] || !seen["4"] || !seen["5"] {
+		t.Errorf("(RANDOM 3 5) gave %v, want 3, 4 and 5", seen)
+	}
+	got := strings.Fields(strings.Trim(seeded(t, "SHUFFLE [c a d b]", 3), "[]"))
+	sort.Strings(got)
+	if strings.Join(got, " ") != "a b c d" {
+		t.Errorf("SHUFFLE gave %q, want the same items", got)
+	}
+}
+
+func TestRandomErrors(t *testing.T) {
+	runErrors(t, []resultTest{
+		{"RANDOM 0", "1:8: RANDOM doesn't like 0 as input"},
+		{"RANDOM 1.5", "1:8: RANDOM doesn't like 1.5 as input"},
+		{"(RANDOM 5 1)", "1:11: RANDOM doesn't like 1 as input"},
+		{"RANDOM 1e30", "1:8: RANDOM doesn't like 1e+30 as input"},
+		{"(RANDOM -9000000000000000000 9000000000000000000)", "1:30: RANDOM doesn't like 9e+18 as input"},
+		{"PICK []", "1:6: PICK doesn't like [] as input"},
+	})
+}
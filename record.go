@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// TurtleCall is one line of a turtle recording: a call to Move, Rotate or
+// PenUp, and the state the turtle was in afterwards.
+type TurtleCall struct {
+	At time.Duration `json:"at"`
+	// Call is "move", "rotate" or "penup".
+	Call    string     `json:"call"`
+	Steps   *float64   `json:"steps,omitempty"`
+	Degrees *float64   `json:"degrees,omitempty"`
+	Up      *bool      `json:"up,omitempty"`
+	State   BaseTurtle `json:"state"`
+	// Error is set if the call failed, part way through or not at all.
+	Error string `json:"error,omitempty"`
+}
+
+// RecordingTurtle passes each call on to Turtle and writes it as a JSON line,
+// so the recording survives the program crashing halfway through a drawing.
+type RecordingTurtle struct {
+	Turtle
+	mu  sync.Mutex
+	enc *json.Encoder
+	// Now reports the time since the recording started.
+	Now func() time.Duration
+	err error
+}
+
+func NewRecordingTurtle(t Turtle, w io.Writer) *RecordingTurtle {
+	start := time.Now()
+	return &RecordingTurtle{
+		Turtle: t,
+		enc:    json.NewEncoder(w),
+		Now:    func() time.Duration { return time.Since(start) },
+	}
+}
+
+func (t *RecordingTurtle) record(c TurtleCall, err error) {
+	c.At = t.Now()
+	c.State = t.Turtle.State()
+	if err != nil {
+		c.Error = err.Error()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = t.enc.Encode(c)
+	}
+}
+
+// Err returns the first error hit while writing the recording.
+func (t *RecordingTurtle) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+func (t *RecordingTurtle) Move(steps float64) (x, y float64, err error) {
+	x, y, err = t.Turtle.Move(steps)
+	t.record(TurtleCall{Call: "move", Steps: &steps}, err)
+	return x, y, err
+}
+
+func (t *RecordingTurtle) Rotate(deg float64) (heading float64, err error) {
+	heading, err = t.Turtle.Rotate(deg)
+	t.record(TurtleCall{Call: "rotate", Degrees: &deg}, err)
+	return heading, err
+}
+
+func (t *RecordingTurtle) PenUp(state bool) (bool, error) {
+	up, err := t.Turtle.PenUp(state)
+	t.record(TurtleCall{Call: "penup", Up: &state}, err)
+	return up, err
+}
+
+// Replay makes the calls in a recording on t. A call that failed when it was
+// recorded is made only as far as it got, going by the state it left.
+func Replay(r io.Reader, t TurtleController) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		var c TurtleCall
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		if err := replayCall(t, c); err != nil {
+			return fmt.Errorf("line %d: %s failed: %v", line, c.Call, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func replayCall(t TurtleController, c TurtleCall) error {
+	var err error
+	switch {
+	case c.Call == "move" && c.Steps != nil:
+		steps := *c.Steps
+		if c.Error != "" {
+			from := t.State()
+			steps = math.Copysign(math.Hypot(c.State.X-from.X, c.State.Y-from.Y), steps)
+		}
+		_, _, err = t.Move(steps)
+	case c.Call == "rotate" && c.Degrees != nil:
+		deg := *c.Degrees
+		if c.Error != "" {
+			deg = turnBy(t.State().Heading, c.State.Heading)
+		}
+		_, err = t.Rotate(deg)
+	case c.Call == "penup" && c.Up != nil:
+		_, err = t.PenUp(c.State.IsPenUp)
+	default:
+		err = fmt.Errorf("unknown call")
+	}
+	return err
+}
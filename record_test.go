@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+// stallingTurtle gets half way through any move longer than 15 steps.
+type stallingTurtle struct {
+	BaseTurtle
+}
+
+func (t *stallingTurtle) Move(steps float64) (x, y float64, err error) {
+	if math.Abs(steps) > 15 {
+		x, y, _ = t.BaseTurtle.Move(steps / 2)
+		return x, y, errors.New("stalled")
+	}
+	return t.BaseTurtle.Move(steps)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	var b bytes.Buffer
+	recording := NewRecordingTurtle(&stallingTurtle{}, &b)
+	program, err := ParseString("", "REPEAT 3 [FD 10 RT 120] PU CATCH \"ERROR [FD 30] PD RT 45 FD 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewContext(recording, nil, ioutil.Discard, nil).RunProgram(program); err != nil {
+		t.Fatal(err)
+	}
+	if err := recording.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed := &BaseTurtle{}
+	if err := Replay(&b, replayed); err != nil {
+		t.Fatalf("Replay() = %v", err)
+	}
+	want := recording.State()
+	if math.Abs(replayed.X-want.X) > 1e-9 || math.Abs(replayed.Y-want.Y) > 1e-9 ||
+		math.Abs(replayed.Heading-want.Heading) > 1e-9 || replayed.IsPenUp != want.IsPenUp {
+		t.Errorf("Replay() left the turtle at %+v, want %+v", *replayed, want)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/chzyer/readline"
+)
+
+const (
+	prompt             = "> "
+	continuationPrompt = "~ "
+)
+
+func runProgramFromStdin(session *Session) error {
+	completer := &procedureCompleter{session: session}
+	config := &readline.Config{
+		Prompt:       prompt,
+		AutoComplete: completer,
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		config.HistoryFile = filepath.Join(home, ".jlogo_history")
+	}
+	rl, err := readline.NewEx(config)
+	if err != nil {
+		return fmt.Errorf("Error starting the REPL, got %v", err)
+	}
+	defer rl.Close()
+	session.Input = &readlineInput{rl: rl}
+
+	var entry []string
+	for {
+		line, err := rl.Readline()
+		if err == readline.ErrInterrupt {
+			// Ctrl-C throws away what's been typed so far.
+			entry = nil
+			rl.SetPrompt(prompt)
+			continue
+		}
+		if err != nil { // io.EOF
+			break
+		}
+		entry = append(entry, line)
+		src := strings.Join(entry, "\n")
+		if incomplete(src) {
+			rl.SetPrompt(continuationPrompt)
+			continue
+		}
+		entry = nil
+		rl.SetPrompt(prompt)
+
+		program, err := ParseString("stdin", src)
+		if err == nil {
+			err = runInterruptible(session, program)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, ShowError(err, "stdin", src))
+		}
+	}
+	return nil
+}
+
+// incomplete reports whether src has brackets or a TO left open, so the REPL
+// should wait for more lines before running it.
+func incomplete(src string) bool {
+	l, err := basicLexer.LexString("", src)
+	if err != nil {
+		return false
+	}
+	symbols := basicLexer.Symbols()
+	depth, open := 0, 0
+	lineStart := true
+	for {
+		t, err := l.Next()
+		if err != nil {
+			return false
+		}
+		if t.EOF() {
+			return depth > 0 || open > 0
+		}
+		switch {
+		case t.Type == symbols["Punct"] && (t.Value == "[" || t.Value == "("):
+			depth++
+		case t.Type == symbols["Punct"] && (t.Value == "]" || t.Value == ")"):
+			depth--
+		case t.Type == symbols["Ident"] && lineStart && strings.EqualFold(t.Value, "TO"):
+			open++
+		case t.Type == symbols["End"]:
+			open--
+		}
+		lineStart = t.Type == symbols["EOL"]
+	}
+}
+
+// procedureCompleter completes the names of primitives and of the session's
+// procedures.
+type procedureCompleter struct {
+	session *Session
+}
+
+func (c *procedureCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	start := pos
+	for start > 0 && isNameRune(line[start-1]) {
+		start--
+	}
+	if start > 0 && (line[start-1] == ':' || line[start-1] == '"') {
+		// Variables and quoted words aren't procedure names.
+		return nil, 0
+	}
+	prefix := string(line[start:pos])
+	if prefix == "" {
+		return nil, 0
+	}
+	upper := strings.ToUpper(prefix)
+	var names []string
+	for name := range primitives {
+		names = append(names, name)
+	}
+	for name := range c.session.Procedures {
+		names = append(names, name)
+	}
+	for name := range c.session.Library {
+		if _, ok := c.session.Procedures[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var suffixes [][]rune
+	for _, name := range names {
+		if !strings.HasPrefix(name, upper) {
+			continue
+		}
+		suffix := name[len(upper):]
+		if prefix == strings.ToLower(prefix) {
+			suffix = strings.ToLower(suffix)
+		}
+		suffixes = append(suffixes, []rune(suffix+" "))
+	}
+	return suffixes, len(prefix)
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || r == '.' || r == '?' ||
+		r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// readlineInput lets READWORD and friends read from the terminal while the
+// REPL owns it, a line at a time.
+type readlineInput struct {
+	rl      *readline.Instance
+	pending []byte
+}
+
+func (r *readlineInput) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		r.rl.SetPrompt("")
+		defer r.rl.SetPrompt(prompt)
+		line, err := r.rl.Readline()
+		if err != nil {
+			return 0, io.EOF
+		}
+		r.pending = []byte(line + "\n")
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"FD 10", false},
+		{"REPEAT 4 [FD 10", true},
+		{"REPEAT 4 [FD 10\nRT 90]", false},
+		{"PRINT (SUM 1", true},
+		{"TO SQ", true},
+		{"TO SQ\nREPEAT 4 [FD 10 RT 90]", true},
+		{"TO SQ\nPRINT [the end]", true},
+		{"TO SQ\nREPEAT 4 [FD 10 RT 90]\nEND", false},
+		{"PRINT [to the end]", false},
+		{"PRINT \"|a [b|", false},
+	}
+	for _, test := range tests {
+		if got := incomplete(test.src); got != test.want {
+			t.Errorf("incomplete(%q) = %v, want %v", test.src, got, test.want)
+		}
+	}
+}
+
+func TestCompleter(t *testing.T) {
+	session := NewSession(&BaseTurtle{}, nil, nil, nil)
+	program, err := ParseString("", "TO SQUIGGLE\nEND")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Run(program, nil); err != nil {
+		t.Fatal(err)
+	}
+	c := &procedureCompleter{session: session}
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"SQU", []string{"IGGLE "}},
+		{"repeat 4 [squ", []string{"iggle "}},
+		{"POLY", []string{"GON "}},
+		{"PENDOWN", []string{" ", "? ", "P "}},
+		{"FD :squ", nil},
+		{"PRINT \"squ", nil},
+	}
+	for _, test := range tests {
+		line := []rune(test.line)
+		suffixes, _ := c.Do(line, len(line))
+		var got []string
+		for _, s := range suffixes {
+			got = append(got, string(s))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Do(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io"
+	"log"
+	"time"
+)
+
+// Session is an interpreter that keeps its variables, procedures and turtle
+// from one program to the next, as at the REPL.
+type Session struct {
+	*Context
+	// Plan runs each program to the end on a DisplayList, and checks it,
+	// before the turtle draws any of it. SLEEP is recorded and waited for
+	// while drawing, but PRINT and READWORD and friends happen while it's
+	// planned.
+	Plan bool
+	// Optimize plans each program and then reorders what it draws to cut
+	// down the travel in between.
+	Optimize bool
+}
+
+func NewSession(turtle TurtleController, r io.Reader, w io.Writer, functions map[string]Function) *Session {
+	return &Session{Context: NewContext(turtle, r, w, functions)}
+}
+
+// Run runs program, stopping at the next instruction once canceled is closed.
+// Whatever it defines is there for the next program.
+func (s *Session) Run(program *Program, canceled <-chan struct{}) error {
+	s.Canceled = canceled
+	defer func() { s.Canceled = nil }()
+	// An error can leave these half way through the last program.
+	s.frames, s.stream, s.slots, s.repcount = nil, nil, nil, -1
+	if !s.Plan && !s.Optimize {
+		return s.RunProgram(program)
+	}
+
+	// target is what draws the list once it's been checked.
+	var target TurtleController
+	list := NewDisplayList(s.Turtle.State())
+	if b, ok := s.Turtle.(*BoundedTurtle); ok {
+		// Plan inside the page, so that leaving it stops the program before
+		// anything is drawn.
+		inner := b.Turtle
+		target = inner
+		b.Turtle = list
+		defer func() { b.Turtle = inner }()
+	} else {
+		target = s.Turtle
+		s.Turtle = list
+		defer func() { s.Turtle = target }()
+	}
+	sleep := s.Sleep
+	s.Sleep = list.Sleep
+	defer func() { s.Sleep = sleep }()
+	err := s.RunProgram(program)
+	if err == nil {
+		err = list.Validate()
+	}
+	if err != nil {
+		log.Print("Stopped before drawing anything")
+		return err
+	}
+	log.Printf("Planned %d moves: %v", len(list.Calls), list.Estimate())
+	if s.Optimize {
+		before := list.Estimate()
+		if list, err = list.Optimize(); err != nil {
+			return err
+		}
+		after := list.Estimate()
+		log.Printf("Optimized to %d moves: %v", len(list.Calls), after)
+		log.Printf("Saved %.1f of travel with the pen up, about %v on the pi turtle",
+			before.Travel-after.Travel, (before.Duration - after.Duration).Round(time.Second))
+	}
+	return list.Play(target, canceled)
+}
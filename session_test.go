@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	tests := []struct {
+		src, want string
+		wantErr   bool
+	}{
+		{"TO SQ :n\nREPEAT 4 [FD :n RT 90]\nEND\nMAKE \"side 5", "", false},
+		{"TO TRI\nREPEAT 3 [FD 1 RT 120]\nEND", "", false},
+		// What the last entry defined is still there.
+		{"SQ :side PRINT :side", "5\n", false},
+		{"POTS", "TO SQ :n\nTO TRI\n", false},
+		{"POPS", "TO SQ :n\n  REPEAT 4 [FD :n RT 90]\nEND\n\nTO TRI\n  REPEAT 3 [FD 1 RT 120]\nEND\n", false},
+		{"ERASE \"TRI POTS", "TO SQ :n\n", false},
+		{"ERASE [SQ NOPE]", "", true},
+		// SQ was erased before NOPE wasn't found; the variables are left alone.
+		{"POTS PRINT :side", "5\n", false},
+		{"ERALL POTS PRINT :side", "", true},
+		{"PRINT 1", "1\n", false},
+	}
+	var out bytes.Buffer
+	session := NewSession(&BaseTurtle{}, nil, &out, nil)
+	for _, test := range tests {
+		out.Reset()
+		program, err := ParseString("stdin", test.src)
+		if err != nil {
+			t.Fatalf("Parse(%q) = %v", test.src, err)
+		}
+		err = session.Run(program, nil)
+		if (err != nil) != test.wantErr {
+			t.Errorf("Run(%q) = %v, want error %v", test.src, err, test.wantErr)
+		}
+		if out.String() != test.want {
+			t.Errorf("Run(%q) printed %q, want %q", test.src, out.String(), test.want)
+		}
+	}
+}
+
+func TestSessionAfterCancel(t *testing.T) {
+	var out bytes.Buffer
+	session := NewSession(&BaseTurtle{}, nil, &out, nil)
+	program, err := ParseString("stdin", "TO SPIN\nFOREVER [RT 1]\nEND\nSPIN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	canceled := make(chan struct{})
+	close(canceled)
+	if err := session.Run(program, canceled); err != ErrCanceled {
+		t.Fatalf("Run(SPIN) = %v, want %v", err, ErrCanceled)
+	}
+	// Canceling in the middle of SPIN doesn't leave the next entry inside it.
+	program, err = ParseString("stdin", "PRINT REPCOUNT POTS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Run(program, nil); err != nil {
+		t.Fatalf("Run after cancel = %v", err)
+	}
+	if got := out.String(); got != "-1\nTO SPIN\n" {
+		t.Errorf("Run after cancel printed %q", got)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"io"
+	"time"
+)
+
+// SimClock is a fake clock for running the pi stack without hardware. Sleep
+// advances the clock instantly, so a long drawing simulates in microseconds.
+type SimClock struct {
+	now time.Duration
+}
+
+// Sleep advances the clock by d.
+func (c *SimClock) Sleep(d time.Duration) {
+	c.now += d
+}
+
+// Now returns the time elapsed since the clock was created.
+func (c *SimClock) Now() time.Duration {
+	return c.now
+}
+
+// PinEvent is a single GPIO pin transition.
+type PinEvent struct {
+	At    time.Duration
+	Pin   int
+	Value bool
+}
+
+// DutyCycleEvent is a single PWM duty cycle change.
+type DutyCycleEvent struct {
+	At        time.Duration
+	Pin       int
+	DutyCycle float64
+}
+
+// SimRecorder collects the pin history of every simulated device that shares
+// it, in the order the writes happened.
+type SimRecorder struct {
+	Clock      *SimClock
+	Pins       []PinEvent
+	DutyCycles []DutyCycleEvent
+}
+
+func NewSimRecorder() *SimRecorder {
+	return &SimRecorder{Clock: &SimClock{}}
+}
+
+// PinHistory returns the transitions of a single pin.
+func (r *SimRecorder) PinHistory(pin int) []PinEvent {
+	var events []PinEvent
+	for _, e := range r.Pins {
+		if e.Pin == pin {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// SimGPIO is an in-memory GPIO pin. Only transitions are recorded; writing the
+// value a pin already has is not an event.
+type SimGPIO struct {
+	Pin      int
+	Recorder *SimRecorder
+	value    bool
+	// Err, if set, is returned by every Enable call.
+	Err error
+}
+
+func (g *SimGPIO) Enable(b bool) error {
+	if g.Err != nil {
+		return g.Err
+	}
+	if b != g.value {
+		g.value = b
+		g.Recorder.Pins = append(g.Recorder.Pins, PinEvent{
+			At:    g.Recorder.Clock.Now(),
+			Pin:   g.Pin,
+			Value: b,
+		})
+	}
+	return nil
+}
+
+// Value returns the current state of the pin.
+func (g *SimGPIO) Value() bool {
+	return g.value
+}
+
+// SimPWM is an in-memory PWM output.
+type SimPWM struct {
+	Pin      int
+	Recorder *SimRecorder
+	Released bool
+}
+
+func (p *SimPWM) DutyCycle(dc float64) error {
+	p.Recorder.DutyCycles = append(p.Recorder.DutyCycles, DutyCycleEvent{
+		At:        p.Recorder.Clock.Now(),
+		Pin:       p.Pin,
+		DutyCycle: dc,
+	})
+	return nil
+}
+
+func (p *SimPWM) Release() error {
+	p.Released = true
+	return nil
+}
+
+// InitSimGPIOPins creates a SimGPIO for each pin, all sharing r.
+func InitSimGPIOPins(r *SimRecorder, pins []int) []GPIO {
+	var gpio []GPIO
+	for _, pin := range pins {
+		gpio = append(gpio, &SimGPIO{Pin: pin, Recorder: r})
+	}
+	return gpio
+}
+
+// NewSimPiTurtle builds the same turtle as InitPiTurtle on top of simulated
+// pins. Every sleep advances the recorder's clock instead of blocking.
+func NewSimPiTurtle(w io.Writer, r *SimRecorder) (*PiTurtle, error) {
+	turtle, err := BuildPiTurtle(w,
+		&SimPWM{Pin: PinPenServo, Recorder: r},
+		InitSimGPIOPins(r, PinsLeftWheel),
+		InitSimGPIOPins(r, PinsRightWheel))
+	if err != nil {
+		return nil, err
+	}
+	turtle.Sleep = r.Clock.Sleep
+	turtle.LeftWheel.(*GPIOStepper).Sleep = r.Clock.Sleep
+	turtle.RightWheel.(*GPIOStepper).Sleep = r.Clock.Sleep
+	return turtle, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+)
+
+// WriteSVG renders strokes, given in the turtle's frame, as an SVG document.
+// The page shows the Logo frame, with the turtle starting out facing up, and
+// pen-up travel is drawn faint and dashed.
+func WriteSVG(w io.Writer, strokes []Stroke) error {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, s := range strokes {
+		for _, p := range s.Points {
+			x, y := LogoPos(BaseTurtle{X: p.X, Y: p.Y})
+			minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+	}
+	if math.IsInf(minX, 1) {
+		minX, minY, maxX, maxY = 0, 0, 0, 0
+	}
+	margin := math.Max(math.Max(maxX-minX, maxY-minY)*0.05, 1)
+	minX, minY = minX-margin, minY-margin
+	maxX, maxY = maxX+margin, maxY+margin
+
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="%.3f %.3f %.3f %.3f" width="800">`+"\n",
+		minX, -maxY, maxX-minX, maxY-minY)
+	if err != nil {
+		return err
+	}
+	for _, s := range strokes {
+		if len(s.Points) < 2 {
+			continue
+		}
+		style := `stroke="black" stroke-width="2"`
+		if s.PenUp {
+			style = `stroke="#ccc" stroke-width="1" stroke-dasharray="4 4"`
+		}
+		if _, err := fmt.Fprintf(w, `<polyline fill="none" vector-effect="non-scaling-stroke" %s points="`, style); err != nil {
+			return err
+		}
+		for i, p := range s.Points {
+			sep := " "
+			if i == 0 {
+				sep = ""
+			}
+			x, y := LogoPos(BaseTurtle{X: p.X, Y: p.Y})
+			if _, err := fmt.Fprintf(w, "%s%.3f,%.3f", sep, x, -y); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprint(w, "\"/>\n"); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprint(w, "</svg>\n")
+	return err
+}
+
+// SVGTurtle draws nothing but remembers its strokes, and writes them to W as
+// an SVG preview when it's closed.
+type SVGTurtle struct {
+	BaseTurtle
+	Strokes []Stroke
+	W       io.WriteCloser
+}
+
+func NewSVGTurtle(w io.WriteCloser) *SVGTurtle {
+	return &SVGTurtle{W: w}
+}
+
+// Move steps. If steps is negative, move backward
+// Should return the current position on completion.
+func (t *SVGTurtle) Move(steps float64) (x, y float64, err error) {
+	from := Point{t.X, t.Y}
+	x, y, err = t.BaseTurtle.Move(steps)
+	last := len(t.Strokes) - 1
+	if last < 0 || t.Strokes[last].PenUp != t.IsPenUp {
+		t.Strokes = append(t.Strokes, Stroke{PenUp: t.IsPenUp, Points: []Point{from}})
+		last++
+	}
+	t.Strokes[last].Points = append(t.Strokes[last].Points, Point{x, y})
+	return x, y, err
+}
+
+func (t *SVGTurtle) Close() {
+	if err := WriteSVG(t.W, t.Strokes); err != nil {
+		log.Printf("Error writing SVG, got %v", err)
+	}
+	if err := t.W.Close(); err != nil {
+		log.Printf("Error writing SVG, got %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Templates are the first input to APPLY, MAP and friends. A template is one of
+//
+//	"SUM            the name of a procedure
+//	[? * 2]         a list using ? (or ?1, ?2, ...) for its inputs
+//	[[x y] :x + :y] a list starting with the names of its inputs
+
+// applyTemplate runs input i as a template with args as its inputs.
+func (ctx *Context) applyTemplate(in *Inputs, i int, args []interface{}) (interface{}, error) {
+	template := in.Values[i]
+	if name, ok := template.(string); ok {
+		return ctx.apply(in.Pos, name, args)
+	}
+	list, err := in.List(i)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list.Items) > 0 {
+		if params, ok := list.Items[0].(List); ok {
+			if len(params.Items) != len(args) {
+				return nil, in.errorf("template %s wants %d inputs, %s gave it %d",
+					FormatValue(list), len(params.Items), in.Name, len(args))
+			}
+			frame := map[string]interface{}{}
+			for j, param := range params.Items {
+				name, ok := toWord(param)
+				if !ok {
+					return nil, in.doesntLike(i)
+				}
+				frame[strings.ToLower(name)] = args[j]
+			}
+			body := List{Items: list.Items[1:]}
+			if list.Source != nil && len(list.Source.Items[0].Data()) == 1 {
+				body.Source = &ListLiteral{Pos: list.Source.Pos, Items: list.Source.Items[1:]}
+			}
+			ctx.frames = append(ctx.frames, frame)
+			defer func() { ctx.frames = ctx.frames[:len(ctx.frames)-1] }()
+			return ctx.runList(in.Pos, body, true)
+		}
+	}
+
+	ctx.slots = append(ctx.slots, args)
+	defer func() { ctx.slots = ctx.slots[:len(ctx.slots)-1] }()
+	return ctx.runList(in.Pos, list, true)
+}
+
+// templateValue is applyTemplate for when the template has to output.
+func (ctx *Context) templateValue(in *Inputs, i int, args []interface{}) (interface{}, error) {
+	value, err := ctx.applyTemplate(in, i, args)
+	if err == nil && value == nil {
+		err = in.errorf("template %s didn't output to %s", FormatValue(in.Values[i]), in.Name)
+	}
+	return value, err
+}
+
+// slot returns the nth input of the innermost template.
+func (ctx *Context) slot(in *Inputs, n int) (interface{}, error) {
+	if len(ctx.slots) == 0 {
+		return nil, in.errorf("%s can only be used inside a template", in.Name)
+	}
+	args := ctx.slots[len(ctx.slots)-1]
+	if n < 1 || n > len(args) {
+		return nil, in.errorf("the template has no input %d", n)
+	}
+	return args[n-1], nil
+}
+
+func init() {
+	definePrimitive(&Primitive{Inputs: 0, MinInputs: 0, MaxInputs: 1, Fn: func(ctx *Context, in *Inputs) (interface{}, error) {
+		n := 1.0
+		if len(in.Values) == 1 {
+			var err error
+			if n, err = in.Number(0); err != nil {
+				return nil, err
+			}
+		}
+		return ctx.slot(in, int(n))
+	}}, "?")
+	for n := 1; n <= 9; n++ {
+		n := n
+		definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+			return ctx.slot(in, n)
+		}), fmt.Sprintf("?%d", n))
+	}
+
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		if word, ok := toWord(in.Values[0]); ok {
+			return ctx.runList(in.Pos, NewList(word), true)
+		}
+		return ctx.RunValue(in, 0, true)
+	}), "RUN")
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		args, err := in.List(1)
+		if err != nil {
+			return nil, err
+		}
+		return ctx.applyTemplate(in, 0, args.Items)
+	}), "APPLY")
+	definePrimitive(variadic(2, 2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		columns, isWord, err := in.columns(1, len(in.Values))
+		if err != nil {
+			return nil, err
+		}
+		var results []interface{}
+		for _, args := range columns {
+			value, err := ctx.templateValue(in, 0, args)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, value)
+		}
+		if isWord {
+			in.Values = results
+			return joinWords(ctx, in)
+		}
+		return NewList(results...), nil
+	}), "MAP")
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		items, isWord, err := in.sequence(1)
+		if err != nil {
+			return nil, err
+		}
+		var kept []interface{}
+		for _, item := range items {
+			value, err := ctx.templateValue(in, 0, []interface{}{item})
+			if err != nil {
+				return nil, err
+			}
+			keep, ok := toBool(value)
+			if !ok {
+				return nil, in.errorf("template %s output %s to %s, it needs true or false",
+					FormatValue(in.Values[0]), FormatValue(value), in.Name)
+			}
+			if keep {
+				kept = append(kept, item)
+			}
+		}
+		return rebuild(kept, isWord), nil
+	}), "FILTER")
+	definePrimitive(variadic(2, 2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		template := len(in.Values) - 1
+		columns, _, err := in.columns(0, template)
+		if err != nil {
+			return nil, err
+		}
+		for _, args := range columns {
+			value, err := ctx.applyTemplate(in, template, args)
+			if err != nil {
+				return nil, err
+			}
+			if value != nil {
+				return nil, in.errorf("You don't say what to do with %s", FormatValue(value))
+			}
+		}
+		return nil, nil
+	}), "FOREACH")
+	definePrimitive(fixed(2, func(ctx *Context, in *Inputs) (interface{}, error) {
+		items, _, err := in.nonEmpty(1)
+		if err != nil {
+			return nil, err
+		}
+		result := items[len(items)-1]
+		for i := len(items) - 2; i >= 0; i-- {
+			result, err = ctx.templateValue(in, 0, []interface{}{items[i], result})
+			if err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}), "REDUCE")
+}
+
+// columns lines up inputs from through to-1, which must all be the same
+// length, and returns their items a column at a time.
+func (in *Inputs) columns(from, to int) ([][]interface{}, bool, error) {
+	var rows [][]interface{}
+	var isWord bool
+	for i := from; i < to; i++ {
+		items, word, err := in.sequence(i)
+		if err != nil {
+			return nil, false, err
+		}
+		if i == from {
+			isWord = word
+		} else if len(items) != len(rows[0]) {
+			return nil, false, in.errorf("%s inputs must all be the same length", in.Name)
+		}
+		rows = append(rows, items)
+	}
+	columns := make([][]interface{}, len(rows[0]))
+	for j := range columns {
+		for _, row := range rows {
+			columns[j] = append(columns[j], row[j])
+		}
+	}
+	return columns, isWord, nil
+}
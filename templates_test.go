@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestTemplates(t *testing.T) {
+	runResults(t, []resultTest{
+		{"MAKE \"x 1 RUN [MAKE \"x :x + 1 MAKE \"x :x * 3] :x", "6"},
+		{"RUN [SUM 1 2]", "3"},
+		{"APPLY \"SUM [1 2]", "3"},
+		{"APPLY [? * ?2] [3 4]", "12"},
+		{"MAP [? * 2] [1 2 3]", "[2 4 6]"},
+		{"MAP [[x] WORD :x \"!] [a b]", "[a! b!]"},
+		{"MAP \"FIRST [[a b] [c d]]", "[a c]"},
+		{"FILTER [? > 1] [0 1 2 3]", "[2 3]"},
+		{"MAKE \"s [] FOREACH [a b] [MAKE \"s FPUT ? :s] :s", "[b a]"},
+		{"REDUCE \"SUM [1 2 3 4]", "10"},
+		{"REDUCE [[a b] WORD :b :a] [x y z]", "zyx"},
+		{"TO DOUBLE :n\nOUTPUT :n * 2\nEND\nMAP \"DOUBLE [1 2]", "[2 4]"},
+	})
+}
+
+func TestTemplateErrors(t *testing.T) {
+	runErrors(t, []resultTest{
+		{"MAP [[x y] :x] [1 2]", "1:1: template [[x y] :x] wants 2 inputs, MAP gave it 1"},
+		{"MAP [FD ?] [1]", "1:1: template [FD ?] didn't output to MAP"},
+		{"REDUCE \"SUM []", "1:13: REDUCE doesn't like [] as input"},
+	})
+}
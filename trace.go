@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TraceEvent is one line of a GPIO trace. Stepper pins set Value, the pen
+// servo sets DutyCycle.
+type TraceEvent struct {
+	At        time.Duration `json:"at"`
+	Pin       int           `json:"pin"`
+	Value     *bool         `json:"value,omitempty"`
+	DutyCycle *float64      `json:"duty_cycle,omitempty"`
+}
+
+// GPIOTrace writes pin transitions as JSON lines while the turtle runs, so a
+// trace survives the program crashing halfway through a drawing.
+type GPIOTrace struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	// Now reports the time since the trace started.
+	Now func() time.Duration
+	err error
+}
+
+func NewGPIOTrace(w io.Writer) *GPIOTrace {
+	start := time.Now()
+	return &GPIOTrace{
+		enc: json.NewEncoder(w),
+		Now: func() time.Duration { return time.Since(start) },
+	}
+}
+
+func (t *GPIOTrace) write(e TraceEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = t.enc.Encode(e)
+	}
+}
+
+// Err returns the first error hit while writing the trace.
+func (t *GPIOTrace) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// Pins wraps each GPIO so that its transitions are traced under the matching
+// pin number.
+func (t *GPIOTrace) Pins(pins []int, gpio []GPIO) []GPIO {
+	var traced []GPIO
+	for i, g := range gpio {
+		traced = append(traced, &tracedGPIO{GPIO: g, Pin: pins[i], Trace: t})
+	}
+	return traced
+}
+
+// PWM wraps p so that its duty cycle changes are traced.
+func (t *GPIOTrace) PWM(pin int, p PWM) PWM {
+	return &tracedPWM{PWM: p, Pin: pin, Trace: t}
+}
+
+type tracedGPIO struct {
+	GPIO
+	Pin   int
+	Trace *GPIOTrace
+	known bool
+	value bool
+}
+
+func (g *tracedGPIO) Enable(b bool) error {
+	if err := g.GPIO.Enable(b); err != nil {
+		return err
+	}
+	if !g.known || g.value != b {
+		g.known, g.value = true, b
+		g.Trace.write(TraceEvent{At: g.Trace.Now(), Pin: g.Pin, Value: &b})
+	}
+	return nil
+}
+
+type tracedPWM struct {
+	PWM
+	Pin   int
+	Trace *GPIOTrace
+}
+
+func (p *tracedPWM) DutyCycle(dc float64) error {
+	if err := p.PWM.DutyCycle(dc); err != nil {
+		return err
+	}
+	p.Trace.write(TraceEvent{At: p.Trace.Now(), Pin: p.Pin, DutyCycle: &dc})
+	return nil
+}
+
+// WriteTrace writes everything the simulated devices recorded in the same
+// format as GPIOTrace.
+func (r *SimRecorder) WriteTrace(w io.Writer) error {
+	var events []TraceEvent
+	for _, e := range r.Pins {
+		value := e.Value
+		events = append(events, TraceEvent{At: e.At, Pin: e.Pin, Value: &value})
+	}
+	for _, e := range r.DutyCycles {
+		dc := e.DutyCycle
+		events = append(events, TraceEvent{At: e.At, Pin: e.Pin, DutyCycle: &dc})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At < events[j].At })
+	enc := json.NewEncoder(w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadTrace reads a trace written by GPIOTrace or SimRecorder.WriteTrace.
+func ReadTrace(r io.Reader) ([]TraceEvent, error) {
+	var events []TraceEvent
+	dec := json.NewDecoder(r)
+	for {
+		var e TraceEvent
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+}
@@ -10,9 +10,10 @@ import (
 )
 
 type BaseTurtle struct {
-	X, Y    float64
-	Heading float64
-	IsPenUp bool
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	Heading float64 `json:"heading"`
+	IsPenUp bool    `json:"pen_up"`
 }
 
 var penStateMap = map[bool]string{
@@ -55,8 +56,8 @@ func (t BaseTurtle) State() BaseTurtle {
 // Move steps. If steps is negative, move backward
 // Should return the current position on completion.
 func (t *BaseTurtle) Move(steps float64) (x, y float64, err error) {
-	t.X = steps * math.Cos(deg2rad(t.Heading))
-	t.Y = steps * math.Sin(deg2rad(t.Heading))
+	t.X += steps * math.Cos(deg2rad(t.Heading))
+	t.Y += steps * math.Sin(deg2rad(t.Heading))
 	return t.X, t.Y, nil
 }
 
@@ -125,6 +126,18 @@ type PiTurtle struct {
 
 const (
 	PinPenServo = 18
+
+	// Duty cycles at the servo's 0 and 90 degree positions.
+	ServoMinDutyCycle = 0.05
+	ServoMaxDutyCycle = 0.2
+
+	// Stepper steps per turtle step and per degree of rotation.
+	// TODO Figure out mapping of steps to stepper steps
+	StepsPerUnit   = 100
+	StepsPerDegree = 23
+
+	// How long the pi turtle waits after each step and pen change.
+	PiStepDelay = 2 * time.Millisecond
 )
 
 var (
@@ -139,39 +152,65 @@ func NewPiTurtle(w io.Writer, pen ServoPen, leftWheel, rightWheel Stepper) *PiTu
 		LeftWheel:  leftWheel,
 		RightWheel: rightWheel,
 		Sleep:      time.Sleep,
-		Delay:      time.Millisecond * 2,
+		Delay:      PiStepDelay,
 	}
 }
 
-func InitPiTurtle() *PiTurtle {
-	pwm, err := NewPiBlaster(PinPenServo)
-	if err != nil {
-		log.Fatal(err)
-	}
-	servo, err := NewPWMServo(pwm, 0, 90, 0.05, 0.2)
+// BuildPiTurtle wires up the pen servo and both wheel steppers on top of the
+// given outputs.
+func BuildPiTurtle(w io.Writer, pwm PWM, leftPins, rightPins []GPIO) (*PiTurtle, error) {
+	servo, err := NewPWMServo(pwm, 0, 90, ServoMinDutyCycle, ServoMaxDutyCycle)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	log.Printf("Servo: %+v", servo)
 	pen := ServoPen{
 		Servo:     servo,
 		UpAngle:   0,
 		DownAngle: 90,
 	}
 
-	leftWheel, err := NewWheel(PinsLeftWheel)
+	leftWheel, err := NewWheel(leftPins)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	rightWheel, err := NewWheel(PinsRightWheel)
+	rightWheel, err := NewWheel(rightPins)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return NewPiTurtle(os.Stdout,
+	return NewPiTurtle(w,
 		pen,
 		leftWheel,
-		rightWheel)
+		rightWheel), nil
+}
+
+// InitPiTurtle sets up the turtle on the real hardware. If trace is not nil,
+// every pin transition is written to it.
+func InitPiTurtle(trace *GPIOTrace) *PiTurtle {
+	var pwm PWM
+	pwm, err := NewPiBlaster(PinPenServo)
+	if err != nil {
+		log.Fatal(err)
+	}
+	leftPins, err := InitGPIOPins(PinsLeftWheel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rightPins, err := InitGPIOPins(PinsRightWheel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if trace != nil {
+		pwm = trace.PWM(PinPenServo, pwm)
+		leftPins = trace.Pins(PinsLeftWheel, leftPins)
+		rightPins = trace.Pins(PinsRightWheel, rightPins)
+	}
+	turtle, err := BuildPiTurtle(os.Stderr, pwm, leftPins, rightPins)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Servo: %+v", turtle.Pen.Servo)
+	return turtle
 }
 
 func (t *PiTurtle) Close() {
@@ -186,14 +225,14 @@ func (t *PiTurtle) Close() {
 func (t *PiTurtle) Move(steps float64) (x, y float64, err error) {
 	//steps *= -1
 	var dir = 1
+	stepperSteps := steps * StepsPerUnit
 	if steps < 0 {
 		dir = -1
-		steps = -steps
+		stepperSteps = -stepperSteps
 	}
-	stepperSteps := steps * 100
-	// TODO Figure out mapping of steps to stepper steps
 	// TODO Figure float -> int issues here
-	for step := 0.0; step < stepperSteps; step++ {
+	done := 0.0
+	for ; done < stepperSteps; done++ {
 		err = t.LeftWheel.StepOne(dir)
 		if err == nil {
 			err = t.RightWheel.StepOne(dir)
@@ -204,7 +243,9 @@ func (t *PiTurtle) Move(steps float64) (x, y float64, err error) {
 		t.Sleep(t.Delay)
 	}
 	if err != nil {
-		return t.Turtle.State().X, t.Turtle.State().Y, err
+		// Keep track of how far the turtle got, so it can find its way home.
+		x, y, _ = t.Turtle.Move(float64(dir) * done / StepsPerUnit)
+		return x, y, err
 	}
 	return t.Turtle.Move(steps)
 }
@@ -213,14 +254,14 @@ func (t *PiTurtle) Move(steps float64) (x, y float64, err error) {
 // Should return the current heading on completion.
 func (t *PiTurtle) Rotate(deg float64) (heading float64, err error) {
 	var dir = 1
+	stepperSteps := deg * StepsPerDegree
 	if deg < 0 {
 		dir = -1
-		deg = -deg
+		stepperSteps = -stepperSteps
 	}
-	stepperSteps := deg * 23
-	// TODO Figure out mapping of deg to stepper steps
 	// TODO Figure float -> int issues here
-	for step := 0.0; step < stepperSteps; step++ {
+	done := 0.0
+	for ; done < stepperSteps; done++ {
 		err = t.LeftWheel.StepOne(dir)
 		if err == nil {
 			err = t.RightWheel.StepOne(-dir)
@@ -231,7 +272,8 @@ func (t *PiTurtle) Rotate(deg float64) (heading float64, err error) {
 		t.Sleep(t.Delay)
 	}
 	if err != nil {
-		return t.Turtle.State().Heading, err
+		heading, _ = t.Turtle.Rotate(float64(dir) * done / StepsPerDegree)
+		return heading, err
 	}
 	return t.Turtle.Rotate(deg)
 }
@@ -250,3 +292,114 @@ func (t *PiTurtle) PenUp(state bool) (bool, error) {
 	t.Sleep(t.Delay)
 	return t.Turtle.PenUp(state)
 }
+
+// Turtles keep their own frame: they start facing along +X and headings grow
+// counter-clockwise. Logo programs see the usual Logo frame instead, where the
+// turtle starts facing north (+Y) at heading 0 and headings grow clockwise.
+
+// LogoPos returns the turtle's position in the Logo frame.
+func LogoPos(s BaseTurtle) (x, y float64) {
+	return -s.Y, s.X
+}
+
+// LogoHeading returns the turtle's heading in the Logo frame.
+func LogoHeading(s BaseTurtle) float64 {
+	return normalizeAngle(-s.Heading)
+}
+
+// normalizeAngle maps deg into [0, 360).
+func normalizeAngle(deg float64) float64 {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	return deg
+}
+
+// turnBy returns the smallest rotation from one heading to another.
+func turnBy(from, to float64) float64 {
+	delta := normalizeAngle(to - from)
+	if delta > 180 {
+		delta -= 360
+	}
+	return delta
+}
+
+// SetHeading turns the turtle to face heading, in the Logo frame.
+func SetHeading(t TurtleController, heading float64) error {
+	delta := turnBy(t.State().Heading, -heading)
+	if delta == 0 {
+		return nil
+	}
+	_, err := t.Rotate(delta)
+	return err
+}
+
+// reacher is a turtle that may refuse to go to some places, like a
+// BoundedTurtle in Fence mode.
+type reacher interface {
+	// Reach reports why the turtle can't go to (x, y) in the Logo frame.
+	Reach(x, y float64) error
+}
+
+// reach checks that t can go to (x, y) before it turns to face there, so a
+// move it refuses leaves it as it was.
+func reach(t TurtleController, x, y float64) error {
+	if r, ok := t.(reacher); ok {
+		return r.Reach(x, y)
+	}
+	return nil
+}
+
+// MoveTo drives the turtle in a straight line to (x, y) in the Logo frame,
+// then turns it back to the heading it started with. The pen is left alone,
+// so this draws if the pen is down.
+func MoveTo(t TurtleController, x, y float64) error {
+	start := t.State()
+	dx, dy := y-start.X, -x-start.Y
+	distance := math.Hypot(dx, dy)
+	if distance < 1e-9 {
+		return nil
+	}
+	if err := reach(t, x, y); err != nil {
+		return err
+	}
+	if delta := turnBy(start.Heading, math.Atan2(dy, dx)*180/math.Pi); delta != 0 {
+		if _, err := t.Rotate(delta); err != nil {
+			return err
+		}
+	}
+	if _, _, err := t.Move(distance); err != nil {
+		return err
+	}
+	if delta := turnBy(t.State().Heading, start.Heading); delta != 0 {
+		if _, err := t.Rotate(delta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushTurtle saves where the turtle is, which way it faces and its pen, for
+// popTurtle to go back to.
+func (ctx *Context) pushTurtle() {
+	ctx.turtles = append(ctx.turtles, ctx.Turtle.State())
+}
+
+// popTurtle drives the turtle back to the state pushTurtle saved last with
+// the pen up, since a physical turtle can't just be put there.
+func (ctx *Context) popTurtle() error {
+	saved := ctx.turtles[len(ctx.turtles)-1]
+	ctx.turtles = ctx.turtles[:len(ctx.turtles)-1]
+	if err := setPen(ctx.Turtle, true); err != nil {
+		return err
+	}
+	x, y := LogoPos(saved)
+	if err := MoveTo(ctx.Turtle, x, y); err != nil {
+		return err
+	}
+	if err := SetHeading(ctx.Turtle, LogoHeading(saved)); err != nil {
+		return err
+	}
+	return setPen(ctx.Turtle, saved.IsPenUp)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Logo values are float64 numbers, string words, bools and Lists.
+
+// List is a Logo list. Lists read straight from program text keep their
+// Source, so that running them as instructions reports the original positions.
+type List struct {
+	Items  []interface{}
+	Source *ListLiteral
+}
+
+func NewList(items ...interface{}) List {
+	return List{Items: items}
+}
+
+// toNumber converts numbers and words that look like numbers.
+func toNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// toBool converts bools and the words TRUE and FALSE.
+func toBool(v interface{}) (bool, bool) {
+	switch v := v.(type) {
+	case bool:
+		return v, true
+	case string:
+		switch strings.ToLower(v) {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// toWord converts anything but a list to its word form.
+func toWord(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64, bool:
+		return FormatValue(v), true
+	}
+	return "", false
+}
+
+// valuesEqual compares the way Logo's = does: numerically if both sides are
+// numbers, ignoring case for words, and item by item for lists.
+func valuesEqual(a, b interface{}) bool {
+	if an, ok := toNumber(a); ok {
+		bn, ok := toNumber(b)
+		return ok && an == bn
+	}
+	switch a := a.(type) {
+	case List:
+		b, ok := b.(List)
+		if !ok || len(a.Items) != len(b.Items) {
+			return false
+		}
+		for i := range a.Items {
+			if !valuesEqual(a.Items[i], b.Items[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	aw, ok := toWord(a)
+	if !ok {
+		return false
+	}
+	bw, ok := toWord(b)
+	return ok && strings.EqualFold(aw, bw)
+}
+
+// FormatValue formats v the way SHOW would, with brackets around lists.
+func FormatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		// Anything this close to zero is a rounding error, like the x of POS
+		// after drawing a square.
+		if math.Abs(v) < 1e-10 {
+			return "0"
+		}
+		if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		// 15 digits hides rounding errors, so SIN 30 is 0.5.
+		return strconv.FormatFloat(v, 'g', 15, 64)
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case List:
+		return "[" + formatItems(v.Items) + "]"
+	}
+	return ""
+}
+
+// quoteWord writes w as a quoted word, between bars if it has spaces,
+// brackets or bars in it.
+func quoteWord(w string) string {
+	if strings.ContainsAny(w, " \t\r\n[]()|") {
+		return `"` + barQuote(w)
+	}
+	return `"` + w
+}
+
+// barQuote puts w between bars, with a backslash before any bar or backslash
+// in it, so that it reads back as one word.
+func barQuote(w string) string {
+	return "|" + barEscaper.Replace(w) + "|"
+}
+
+var barEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+
+// unbar reads a word written by barQuote. It reports false if w isn't
+// between bars.
+func unbar(w string) (string, bool) {
+	if len(w) < 2 || !strings.HasPrefix(w, "|") || !strings.HasSuffix(w, "|") {
+		return w, false
+	}
+	var b strings.Builder
+	inner := w[1 : len(w)-1]
+	for i := 0; i < len(inner); i++ {
+		if inner[i] == '\\' && i+1 < len(inner) {
+			i++
+		}
+		b.WriteByte(inner[i])
+	}
+	return b.String(), true
+}
+
+// PrintValue formats v the way PRINT would, without brackets around the
+// outermost list.
+func PrintValue(v interface{}) string {
+	if list, ok := v.(List); ok {
+		return formatItems(list.Items)
+	}
+	return FormatValue(v)
+}
+
+func formatItems(items []interface{}) string {
+	words := make([]string, len(items))
+	for i, item := range items {
+		words[i] = FormatValue(item)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The workspace is everything defined so far: procedures and global
+// variables.
+
+// sortedProcedures returns the procedures in order of name.
+func (ctx *Context) sortedProcedures() []*To {
+	var names []string
+	for name := range ctx.Procedures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	procs := make([]*To, len(names))
+	for i, name := range names {
+		procs[i] = ctx.Procedures[name]
+	}
+	return procs
+}
+
+// title is the first line of a procedure, as POTS prints it.
+func title(proc *To) string {
+	words := []string{"TO", strings.ToUpper(proc.Name)}
+	for _, param := range proc.Params {
+		words = append(words, ":"+param)
+	}
+	return strings.Join(words, " ")
+}
+
+// names returns input i as a list of names, whether it's one word or a list.
+func (in *Inputs) names(i int) ([]string, error) {
+	if word, ok := toWord(in.Values[i]); ok {
+		return []string{word}, nil
+	}
+	list, err := in.List(i)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(list.Items))
+	for j, item := range list.Items {
+		name, ok := toWord(item)
+		if !ok {
+			return nil, in.doesntLike(i)
+		}
+		names[j] = name
+	}
+	return names, nil
+}
+
+// baseDir is the directory relative file names are resolved against: that of
+// the program being run, or the working directory at the REPL.
+func baseDir(filename string) string {
+	if filename == "" || filename == "stdin" {
+		return "."
+	}
+	return filepath.Dir(filename)
+}
+
+// findFile looks for name in dir and then in each directory of searchPath,
+// trying name.logo too.
+func findFile(dir string, searchPath []string, name string) (string, bool) {
+	candidates := []string{name}
+	if filepath.Ext(name) == "" {
+		candidates = append(candidates, name+".logo")
+	}
+	dirs := []string{""}
+	if !filepath.IsAbs(name) {
+		dirs = append([]string{dir}, searchPath...)
+	}
+	for _, dir := range dirs {
+		for _, candidate := range candidates {
+			path := filepath.Join(dir, candidate)
+			if _, err := os.Stat(path); err == nil {
+				return path, true
+			}
+		}
+	}
+	return "", false
+}
+
+// workspace is everything defined so far as Logo source.
+func (ctx *Context) workspace() string {
+	var b strings.Builder
+	b.WriteString(FormatProcedures(ctx.sortedProcedures(), false))
+	var names []string
+	for name, value := range ctx.Vars {
+		if value != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) > 0 && b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	for _, name := range names {
+		fmt.Fprintf(&b, "MAKE \"%s %s\n", name, valueSource(ctx.Vars[name], false))
+	}
+	return b.String()
+}
+
+// valueSource writes v as Logo source that gives v back when it's run, or
+// when it's read as an item of a list if inList is set. Numbers are written
+// with every digit, and true and false as words, since Logo has no literal
+// for them.
+func valueSource(v interface{}, inList bool) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		if inList {
+			return FormatValue(v)
+		}
+		return quoteWord(FormatValue(v))
+	case string:
+		if inList {
+			return listWord(v)
+		}
+		return quoteWord(v)
+	case List:
+		items := make([]string, len(v.Items))
+		for i, item := range v.Items {
+			items[i] = valueSource(item, true)
+		}
+		return "[" + strings.Join(items, " ") + "]"
+	}
+	return FormatValue(v)
+}
+
+// listWord writes w as an item of a list. The empty word, or one with spaces,
+// brackets, bars or a comment in it, goes between bars, unless it's a quoted word that
+// already reads back as itself.
+func listWord(w string) string {
+	if w != "" && !strings.ContainsAny(w, " \t\r\n[]();#|") {
+		return w
+	}
+	if strings.HasPrefix(w, `"`) {
+		if inner, ok := unbar(w[1:]); ok && quoteWord(inner) == w {
+			return w
+		}
+	}
+	return barQuote(w)
+}
+
+func init() {
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		for _, proc := range ctx.sortedProcedures() {
+			if _, err := fmt.Fprintln(ctx.Output, title(proc)); err != nil {
+				return nil, in.errorf("%s can't write output: %s", in.Name, err)
+			}
+		}
+		return nil, nil
+	}), "POTS")
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		_, err := fmt.Fprint(ctx.Output, FormatProcedures(ctx.sortedProcedures(), false))
+		if err != nil {
+			return nil, in.errorf("%s can't write output: %s", in.Name, err)
+		}
+		return nil, nil
+	}), "POPS")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		names, err := in.names(0)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			key := strings.ToUpper(name)
+			if _, ok := ctx.Procedures[key]; !ok {
+				return nil, in.errorf("I don't know how to %s", name)
+			}
+			delete(ctx.Procedures, key)
+		}
+		return nil, nil
+	}), "ERASE", "ER")
+	definePrimitive(fixed(0, func(ctx *Context, in *Inputs) (interface{}, error) {
+		ctx.Procedures = map[string]*To{}
+		ctx.Vars = map[string]interface{}{}
+		return nil, nil
+	}), "ERALL")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		name, err := in.Word(0)
+		if err != nil {
+			return nil, err
+		}
+		path, ok := findFile(baseDir(in.Pos.Filename), ctx.SearchPath, name)
+		if !ok {
+			return nil, in.errorf("%s can't find %s", in.Name, name)
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, wrapf(in.Pos, err, "%s can't read %s", in.Name, path)
+		}
+		program, err := ParseString(path, string(src))
+		if err != nil {
+			return nil, err
+		}
+		return nil, ctx.RunProgram(program)
+	}), "LOAD")
+	definePrimitive(fixed(1, func(ctx *Context, in *Inputs) (interface{}, error) {
+		name, err := in.Word(0)
+		if err != nil {
+			return nil, err
+		}
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir(in.Pos.Filename), path)
+		}
+		if err := ioutil.WriteFile(path, []byte(ctx.workspace()), 0644); err != nil {
+			return nil, wrapf(in.Pos, err, "%s can't write %s", in.Name, path)
+		}
+		return nil, nil
+	}), "SAVE")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	src := `TO SQ :n
+  REPEAT 4 [FD :n RT 90]
+END
+MAKE "third 0.1 + 0.2
+MAKE "big 2 ^ 70
+MAKE "negative -1 / 3
+MAKE "yes 1 = 1
+MAKE "word "hello
+MAKE "spaced WORD "|a b| "|[c]|
+MAKE "list [a [1.5 b] "c]
+MAKE "numbers LIST 1 / 3 "false
+MAKE "barred (LIST "|a b| 1 "|x]| "|semi;colon| "|bar\|| "|back\\slash| "|| [|c d|])
+MAKE "tiny 0.00000000001
+TO TINY
+  FD 0.00000000001
+END
+SAVE "saved.logo
+`
+	program, err := ParseString(filepath.Join(dir, "main.logo"), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := NewContext(&BaseTurtle{}, nil, ioutil.Discard, nil)
+	if err := saved.RunProgram(program); err != nil {
+		t.Fatal(err)
+	}
+
+	program, err = ParseString(filepath.Join(dir, "main.logo"), "LOAD \"saved\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewContext(&BaseTurtle{}, nil, ioutil.Discard, nil)
+	if err := loaded.RunProgram(program); err != nil {
+		text, _ := ioutil.ReadFile(filepath.Join(dir, "saved.logo"))
+		t.Fatalf("LOAD = %v, of\n%s", err, text)
+	}
+	for name, want := range saved.Vars {
+		got := loaded.Vars[name]
+		if !valuesEqual(got, want) {
+			t.Errorf(":%s = %s after LOAD, want %s", name, FormatValue(got), FormatValue(want))
+		}
+		if n, ok := want.(float64); ok && got != n {
+			t.Errorf(":%s = %v after LOAD, want exactly %v", name, got, n)
+		}
+	}
+	for _, name := range []string{"SQ", "TINY"} {
+		if _, ok := loaded.Procedures[name]; !ok {
+			t.Errorf("%s isn't defined after LOAD", name)
+		}
+	}
+	got, err := resultIn(loaded, "TINY YCOR * 1e12")
+	if err != nil || got != "10" {
+		t.Errorf("TINY YCOR * 1e12 = %q, %v after LOAD, want 10", got, err)
+	}
+	if again := loaded.workspace(); again != saved.workspace() {
+		t.Errorf("saving again gave\n%s\nwant\n%s", again, saved.workspace())
+	}
+}